@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/k0l1br1/loader/candles"
+)
+
+const importArchiveUsage = `usage: loader import-archive [options] <file.zip>...
+    -s, --symbol        The pair of the data (default is the archive name)
+    -i, --interval      The kline interval (default is the archive name)
+    --exchange          The exchange of the data (default binance)
+    -n, --is-new        The flag to init new instance for a symbol
+    -x, --extended      Store quote volume, number of trades and taker buy
+                        volumes along with OHLCV
+    --encoding          How to store prices and volumes of a new instance,
+                        float32 or float64 (default float32)
+`
+
+var (
+	errReqArchive      = errors.New("archive file is required")
+	errArchiveMismatch = errors.New("archive of another symbol or interval")
+)
+
+type importArchiveOptions struct {
+	IsNew    bool
+	Extended bool
+	Exchange string
+	Symbol   string
+	Interval candles.Interval
+	Encoding candles.Encoding
+	Files    []string
+}
+
+func parseImportArchiveOptions(args []string) (*importArchiveOptions, error) {
+	if len(args) < 2 {
+		printUsage(importArchiveUsage)
+	}
+	opts := &importArchiveOptions{Exchange: candles.DefaultExchange}
+
+	// args[0] is the command name
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		j := i + 1
+		hasValue := len(args) > j && !strings.HasPrefix(args[j], "-")
+		switch arg {
+		case "-h", "--help":
+			printUsage(importArchiveUsage)
+		case "-s", "--symbol":
+			if hasValue {
+				opts.Symbol = strings.ToUpper(args[j])
+				i++
+			}
+		case "-i", "--interval":
+			if hasValue {
+				interval, err := candles.ParseInterval(args[j])
+				if err != nil {
+					return nil, errorWrap("parse options interval", err)
+				}
+				opts.Interval = interval
+				i++
+			}
+		case "--exchange":
+			if hasValue {
+				exchange, err := parseExchange(args[j])
+				if err != nil {
+					return nil, errorWrap("parse options exchange", err)
+				}
+				opts.Exchange = exchange
+				i++
+			}
+		case "-n", "--is-new":
+			opts.IsNew = true
+		case "-x", "--extended":
+			opts.Extended = true
+		case "--encoding":
+			if hasValue {
+				e, err := candles.ParseEncoding(args[j])
+				if err != nil {
+					return nil, errorWrap("parse options encoding", err)
+				}
+				opts.Encoding = e
+				i++
+			}
+		default:
+			if !strings.HasPrefix(arg, "-") {
+				opts.Files = append(opts.Files, arg)
+			}
+		}
+	}
+
+	if len(opts.Files) == 0 {
+		return nil, errReqArchive
+	}
+	return opts, nil
+}
+
+// Check the archives are of the same dataset and sort them in time,
+// the symbol and the interval are taken from the names if they are not set
+func sortArchives(opts *importArchiveOptions) error {
+	dates := make(map[string]string, len(opts.Files))
+	for _, f := range opts.Files {
+		a, err := candles.ParseArchiveName(f)
+		if err != nil {
+			if opts.Symbol == "" || opts.Interval == "" {
+				return errorWrap("set the symbol and the interval", err)
+			}
+			// archives of unknown dates go first in the order of the command line
+			continue
+		}
+		if opts.Symbol == "" {
+			opts.Symbol = a.Symbol
+		}
+		if opts.Interval == "" {
+			opts.Interval = a.Interval
+		}
+		if a.Symbol != opts.Symbol || a.Interval != opts.Interval {
+			return errorWrap(filepath.Base(f), errArchiveMismatch)
+		}
+		dates[f] = a.Date
+	}
+	// a month goes before its days
+	slices.SortStableFunc(opts.Files, func(a, b string) int {
+		return strings.Compare(dates[a], dates[b])
+	})
+	return nil
+}
+
+func runImportArchive(args []string) int {
+	opts, err := parseImportArchiveOptions(args)
+	if err != nil {
+		errorPrint(err)
+		if err == errReqArchive {
+			return exitOk
+		}
+		return exitError
+	}
+	if err = sortArchives(opts); err != nil {
+		errorPrint(err)
+		return exitError
+	}
+	// nothing is imported from a set with a broken archive
+	for _, f := range opts.Files {
+		if err = candles.VerifyArchive(f); err != nil {
+			errorPrint(errorWrap("verify archive", err))
+			return exitError
+		}
+	}
+
+	var stg *candles.Storage
+	if opts.IsNew {
+		format := candles.Format{Layout: candles.LayoutCompact, Encoding: opts.Encoding}
+		if opts.Extended {
+			format.Layout = candles.LayoutExtended
+		}
+		stg, err = candles.NewDefaultStorage(candles.Header{
+			Format:   format,
+			Symbol:   opts.Symbol,
+			Interval: opts.Interval,
+			Exchange: opts.Exchange,
+		})
+	} else {
+		stg, err = openAppendStorage(opts.Exchange, opts.Symbol, opts.Interval)
+	}
+	if err != nil {
+		errorPrint(errorWrap("open storage", err))
+		return exitError
+	}
+	defer stg.Close()
+
+	var imported int64
+	for _, f := range opts.Files {
+		n, err := candles.ImportArchive(stg, f)
+		imported += n
+		if err != nil {
+			errorPrint(errorWrap("import archive "+filepath.Base(f), err))
+			return exitError
+		}
+		fmt.Printf("%s: imported %d candles\n", filepath.Base(f), n)
+	}
+	if err = stg.Sync(); err != nil {
+		errorPrint(errorWrap("sync storage", err))
+		return exitError
+	}
+	total, err := stg.SizeCandles()
+	if err != nil {
+		errorPrint(errorWrap("get total candles", err))
+		return exitError
+	}
+	fmt.Printf("Imported %d %s %s candles, total candles %d\n", imported, opts.Symbol, opts.Interval, total)
+	return exitOk
+}
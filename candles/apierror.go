@@ -0,0 +1,72 @@
+package candles
+
+import (
+	"fmt"
+
+	"github.com/valyala/fasthttp"
+)
+
+// Binance error codes
+const (
+	CodeTooManyRequests = -1003
+	CodeBadSymbol       = -1121
+	CodeInvalidInterval = -1120
+)
+
+// A response with a not OK status, the code and the message are taken
+// from the {"code":-1121,"msg":"Invalid symbol."} body if there is one
+type APIError struct {
+	Status int
+	Code   int
+	Msg    string
+}
+
+func (e *APIError) Error() string {
+	if e.Msg == "" {
+		return fmt.Sprintf("api status %d", e.Status)
+	}
+	return fmt.Sprintf("api status %d, code %d: %s", e.Status, e.Code, e.Msg)
+}
+
+// The symbol is unknown to the exchange
+func (e *APIError) InvalidSymbol() bool {
+	return e.Code == CodeBadSymbol
+}
+
+// The request weight limit is exceeded or the IP is banned
+func (e *APIError) RateLimited() bool {
+	return e.Status == fasthttp.StatusTooManyRequests ||
+		e.Status == fasthttp.StatusTeapot ||
+		e.Code == CodeTooManyRequests
+}
+
+// The failure is on the exchange side, the request may succeed later
+func (e *APIError) ServerSide() bool {
+	return e.Status >= fasthttp.StatusInternalServerError
+}
+
+// Makes errors.Is(err, ErrRateLimited) and errors.Is(err, ErrServerStatus) work
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrRateLimited:
+		return e.RateLimited()
+	case ErrServerStatus:
+		return e.ServerSide()
+	}
+	return false
+}
+
+// Build the error from a response with a not OK status,
+// a body which is not a Binance error leaves the code and the message empty
+func parseAPIError(status int, body []byte) *APIError {
+	e := &APIError{Status: status}
+	parser := parserPool.Get()
+	defer parserPool.Put(parser)
+	v, err := parser.ParseBytes(body)
+	if err != nil {
+		return e
+	}
+	e.Code = v.GetInt("code")
+	e.Msg = string(v.GetStringBytes("msg"))
+	return e
+}
@@ -0,0 +1,39 @@
+package candles
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAPIError(t *testing.T) {
+	err := error(parseAPIError(400, []byte(`{"code":-1121,"msg":"Invalid symbol."}`)))
+	err = errorWrap("api do request", err)
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("want *APIError, got %T", err)
+	}
+	if apiErr.Status != 400 || apiErr.Code != CodeBadSymbol || apiErr.Msg != "Invalid symbol." {
+		t.Errorf("parse api error: got %#v", apiErr)
+	}
+	if !apiErr.InvalidSymbol() || apiErr.RateLimited() || apiErr.ServerSide() {
+		t.Errorf("invalid symbol classified wrong: %#v", apiErr)
+	}
+	if isTransient(err) {
+		t.Error("invalid symbol must not be retried")
+	}
+
+	err = parseAPIError(429, []byte(`{"code":-1003,"msg":"Too many requests."}`))
+	if !errors.Is(err, ErrRateLimited) || !isTransient(err) {
+		t.Errorf("want rate limit error, got '%v'", err)
+	}
+
+	// a proxy may answer with html
+	err = parseAPIError(502, []byte(`<html>Bad Gateway</html>`))
+	if !errors.Is(err, ErrServerStatus) || !isTransient(err) {
+		t.Errorf("want server error, got '%v'", err)
+	}
+	if err.Error() != "api status 502" {
+		t.Errorf("error message: want 'api status 502', got '%s'", err.Error())
+	}
+}
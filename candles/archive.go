@@ -0,0 +1,221 @@
+package candles
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// the checksum of an archive is in the file of the same name with the suffix
+	ChecksumSuffix = ".CHECKSUM"
+	// candles saved at once
+	archiveBatch = 4096
+	// the spot archives have micro seconds since 2025, milli seconds are shorter
+	microThreshold = 1e14
+)
+
+var (
+	ErrChecksumMismatch   = errors.New("archive checksum mismatch")
+	ErrInvalidArchiveName = errors.New("archive name must be like BTCUSDT-1m-2024-01.zip")
+	errArchiveFields      = errors.New("too few fields")
+)
+
+// What a name of data.binance.vision archive tells about its data
+type ArchiveName struct {
+	Symbol   string
+	Interval Interval
+	// 2024-01 of a monthly archive or 2024-01-02 of a daily one,
+	// names of the same dataset sort in time
+	Date string
+}
+
+// Parse names like BTCUSDT-1m-2024-01.zip, BTCUSDT-1s-2024-01-02.zip
+// or BTCUSDT-1mo-2024-01.zip of the monthly interval
+func ParseArchiveName(path string) (ArchiveName, error) {
+	name := strings.TrimSuffix(filepath.Base(path), ".zip")
+	parts := strings.SplitN(name, "-", 3)
+	if len(parts) != 3 || parts[0] == "" {
+		return ArchiveName{}, errorWrap(path, ErrInvalidArchiveName)
+	}
+	// the monthly interval is named 1mo like in the data file names
+	if parts[1] == "1mo" {
+		parts[1] = string(Interval1M)
+	}
+	interval, err := ParseInterval(parts[1])
+	if err != nil {
+		return ArchiveName{}, errorWrap(path, err)
+	}
+	return ArchiveName{Symbol: strings.ToUpper(parts[0]), Interval: interval, Date: parts[2]}, nil
+}
+
+// Compare the SHA256 of the archive with the one of its CHECKSUM file
+func VerifyArchive(path string) error {
+	b, err := os.ReadFile(path + ChecksumSuffix)
+	if err != nil {
+		return err
+	}
+	// the hash is followed by the archive name
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return errorWrap(path+ChecksumSuffix, ErrChecksumMismatch)
+	}
+	want, err := hex.DecodeString(fields[0])
+	if err != nil {
+		return errorWrap(path+ChecksumSuffix, err)
+	}
+
+	fd, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, fd); err != nil {
+		return err
+	}
+	if string(h.Sum(nil)) != string(want) {
+		return errorWrap(path, ErrChecksumMismatch)
+	}
+	return nil
+}
+
+// Append the candles of the CSV files of the archive to the storage.
+// The candles closed not later than the last stored one are skipped,
+// so archives overlapping each other or the storage may be imported in time order
+func ImportArchive(stg *Storage, path string) (int64, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return 0, err
+	}
+	defer zr.Close()
+
+	last, err := stg.LastCandleCloseTime()
+	if err != nil {
+		return 0, errorWrap("read last close time", err)
+	}
+	var total int64
+	for _, f := range zr.File {
+		if !strings.HasSuffix(f.Name, ".csv") {
+			continue
+		}
+		n, err := importCSV(stg, f, &last)
+		total += n
+		if err != nil {
+			return total, errorWrap(f.Name, err)
+		}
+	}
+	return total, nil
+}
+
+func importCSV(stg *Storage, f *zip.File, last *int64) (int64, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	r := csv.NewReader(rc)
+	r.ReuseRecord = true
+	r.FieldsPerRecord = -1
+	cs := make([]Candle, 0, archiveBatch)
+	var total int64
+	for line := 1; ; line++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return total, err
+		}
+		var c Candle
+		if err = parseArchiveRow(rec, &c); err != nil {
+			// newer archives start with the column names
+			if line == 1 {
+				continue
+			}
+			return total, errorWrap("line "+strconv.Itoa(line), err)
+		}
+		if SecToMilli(c.CTime) <= *last {
+			continue
+		}
+		*last = SecToMilli(c.CTime)
+		cs = append(cs, c)
+		if len(cs) == cap(cs) {
+			if err = stg.Save(cs); err != nil {
+				return total, errorWrap("save candles", err)
+			}
+			total += int64(len(cs))
+			cs = cs[:0]
+		}
+	}
+	if err = stg.Save(cs); err != nil {
+		return total, errorWrap("save candles", err)
+	}
+	return total + int64(len(cs)), nil
+}
+
+// Parse a row of the same columns as the klines of the REST API
+func parseArchiveRow(rec []string, c *Candle) error {
+	if len(rec) < 11 {
+		return errArchiveFields
+	}
+	ot, err := parseArchiveTime(rec[0])
+	if err != nil {
+		return errorWrap("parse open time", err)
+	}
+	ct, err := parseArchiveTime(rec[6])
+	if err != nil {
+		return errorWrap("parse close time", err)
+	}
+	// Milli to seconds, the close time is the last milli second of the candle
+	c.OTime = uint32(ot / 1000)
+	c.CTime = uint32(ct/1000) + 1
+
+	fields := [...]struct {
+		name string
+		col  int
+		dst  *float64
+	}{
+		{"open price", 1, &c.OPrice},
+		{"high price", 2, &c.HPrice},
+		{"low price", 3, &c.LPrice},
+		{"close price", 4, &c.CPrice},
+		{"volume", 5, &c.Volume},
+		{"quote volume", 7, &c.QVolume},
+		{"taker buy volume", 9, &c.TBVolume},
+		{"taker buy quote volume", 10, &c.TQVolume},
+	}
+	for _, f := range fields {
+		p, err := strconv.ParseFloat(rec[f.col], 64)
+		if err != nil {
+			return errorWrap("parse "+f.name, err)
+		}
+		*f.dst = p
+	}
+	trades, err := strconv.ParseUint(rec[8], 10, 32)
+	if err != nil {
+		return errorWrap("parse number of trades", err)
+	}
+	c.Trades = uint32(trades)
+	return nil
+}
+
+// Returns milli seconds of a time in milli or micro seconds
+func parseArchiveTime(s string) (int64, error) {
+	t, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if t >= microThreshold {
+		t /= 1000
+	}
+	return t, nil
+}
@@ -0,0 +1,112 @@
+package candles
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Write an archive of one CSV file and its checksum file
+func writeArchive(t *testing.T, dir, name, rows string) string {
+	path := filepath.Join(dir, name)
+	fd, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("create archive: %s", err.Error())
+	}
+	zw := zip.NewWriter(fd)
+	w, err := zw.Create(name[:len(name)-len(".zip")] + ".csv")
+	if err != nil {
+		t.Fatalf("create archive entry: %s", err.Error())
+	}
+	w.Write([]byte(rows))
+	if err = zw.Close(); err != nil {
+		t.Fatalf("close archive: %s", err.Error())
+	}
+	fd.Close()
+
+	b, _ := os.ReadFile(path)
+	sum := sha256.Sum256(b)
+	checksum := hex.EncodeToString(sum[:]) + "  " + name + "\n"
+	if err = os.WriteFile(path+ChecksumSuffix, []byte(checksum), DefaultFilePerm); err != nil {
+		t.Fatalf("write checksum: %s", err.Error())
+	}
+	return path
+}
+
+func TestParseArchiveName(t *testing.T) {
+	a, err := ParseArchiveName("data/BTCUSDT-1s-2024-01-02.zip")
+	if err != nil {
+		t.Fatalf("parse archive name: %s", err.Error())
+	}
+	want := ArchiveName{Symbol: "BTCUSDT", Interval: Interval1s, Date: "2024-01-02"}
+	if a != want {
+		t.Errorf("archive name: want %#v, got %#v", want, a)
+	}
+	a, err = ParseArchiveName("BTCUSDT-1mo-2024-01.zip")
+	if err != nil {
+		t.Fatalf("parse monthly interval archive name: %s", err.Error())
+	}
+	want = ArchiveName{Symbol: "BTCUSDT", Interval: Interval1M, Date: "2024-01"}
+	if a != want {
+		t.Errorf("archive name: want %#v, got %#v", want, a)
+	}
+	if _, err = ParseArchiveName("BTCUSDT.zip"); !errors.Is(err, ErrInvalidArchiveName) {
+		t.Errorf("want error '%s', got '%v'", ErrInvalidArchiveName, err)
+	}
+}
+
+func TestImportArchive(t *testing.T) {
+	dir := t.TempDir()
+	first := writeArchive(t, dir, "BTCUSDT-1m-2024-02.zip",
+		"1708300800000,1.1,1.3,1.0,1.2,10.5,1708300859999,12.6,3,5.0,6.0,0\n"+
+			"1708300860000,1.2,1.4,1.1,1.3,20.5,1708300919999,26.6,4,7.0,8.0,0\n")
+	// micro seconds and the column names, the first candle is already stored
+	second := writeArchive(t, dir, "BTCUSDT-1m-2024-02-19.zip",
+		"open_time,open,high,low,close,volume,close_time,quote_volume,count,taker_buy_volume,taker_buy_quote_volume,ignore\n"+
+			"1708300860000000,1.2,1.4,1.1,1.3,20.5,1708300919999999,26.6,4,7.0,8.0,0\n"+
+			"1708300920000000,1.3,1.5,1.2,1.4,30.5,1708300979999999,36.6,5,9.0,10.0,0\n")
+	for _, path := range []string{first, second} {
+		if err := VerifyArchive(path); err != nil {
+			t.Errorf("verify archive: %s", err.Error())
+		}
+	}
+
+	stg, err := NewFileStorage(filepath.Join(dir, "BTCUSDT-1m.bin"), Header{
+		Format:   Format{Layout: LayoutExtended, Encoding: EncodingFloat64},
+		Symbol:   "BTCUSDT",
+		Interval: Interval1m,
+	})
+	if err != nil {
+		t.Fatalf("create new storage: %s", err.Error())
+	}
+	defer stg.Close()
+	var total int64
+	for _, path := range []string{first, second} {
+		n, err := ImportArchive(stg, path)
+		if err != nil {
+			t.Fatalf("import archive: %s", err.Error())
+		}
+		total += n
+	}
+	if total != 3 {
+		t.Errorf("imported candles: want 3, got %d", total)
+	}
+
+	cs, err := stg.ReadAll()
+	if err != nil {
+		t.Fatalf("read all candles: %s", err.Error())
+	}
+	want := Candle{1.3, 1.5, 1.2, 1.4, 30.5, 1708300920, 1708300980, 36.6, 5, 9.0, 10.0}
+	if len(cs) != 3 || cs[2] != want {
+		t.Errorf("candles: want 3 and the last %#v, got %#v", want, cs)
+	}
+
+	os.WriteFile(first, []byte("broken"), DefaultFilePerm)
+	if err = VerifyArchive(first); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("want error '%s', got '%v'", ErrChecksumMismatch, err)
+	}
+}
@@ -0,0 +1,146 @@
+package candles
+
+import (
+	"os"
+	"time"
+)
+
+const (
+	ExchangeBinanceUSDM        = "binance-usdm"
+	ExchangeBinanceUSDMMark    = "binance-usdm-mark"
+	ExchangeBinanceUSDMIndex   = "binance-usdm-index"
+	ExchangeBinanceUSDMPremium = "binance-usdm-premium"
+
+	ExchangeBinanceCOINM        = "binance-coinm"
+	ExchangeBinanceCOINMMark    = "binance-coinm-mark"
+	ExchangeBinanceCOINMIndex   = "binance-coinm-index"
+	ExchangeBinanceCOINMPremium = "binance-coinm-premium"
+
+	fapiBaseURL = "https://fapi.binance.com"
+	dapiBaseURL = "https://dapi.binance.com"
+	// the futures endpoints allow 1500 candles, it costs the weight of 10
+	futuresLimit  = 1500
+	futuresWeight = 10
+	// COIN-M returns at most 200 days of candles from the start time
+	coinmMaxRange = 200 * 24 * int64(time.Hour/time.Millisecond)
+)
+
+// An endpoint of Binance klines, all of them return the same arrays
+type binanceAPI struct {
+	name    string
+	baseURL string
+	path    string
+	// the index price klines are requested by the pair, like BTCUSD for BTCUSD_PERP
+	param  string
+	limit  int
+	weight int
+	// the widest range of the open times of one request, 0 means no limit
+	maxRange int64
+	// the futures do not have the 1s interval
+	noSeconds bool
+}
+
+var (
+	binanceSpot = binanceAPI{ExchangeBinance, apiBaseURL, apiKlinesPath, "symbol", len(Candles{}), klinesWeight, 0, false}
+
+	binanceUSDM        = binanceAPI{ExchangeBinanceUSDM, fapiBaseURL, "/fapi/v1/klines", "symbol", futuresLimit, futuresWeight, 0, true}
+	binanceUSDMMark    = binanceAPI{ExchangeBinanceUSDMMark, fapiBaseURL, "/fapi/v1/markPriceKlines", "symbol", futuresLimit, futuresWeight, 0, true}
+	binanceUSDMIndex   = binanceAPI{ExchangeBinanceUSDMIndex, fapiBaseURL, "/fapi/v1/indexPriceKlines", "pair", futuresLimit, futuresWeight, 0, true}
+	binanceUSDMPremium = binanceAPI{ExchangeBinanceUSDMPremium, fapiBaseURL, "/fapi/v1/premiumIndexKlines", "symbol", futuresLimit, futuresWeight, 0, true}
+
+	binanceCOINM        = binanceAPI{ExchangeBinanceCOINM, dapiBaseURL, "/dapi/v1/klines", "symbol", futuresLimit, futuresWeight, coinmMaxRange, true}
+	binanceCOINMMark    = binanceAPI{ExchangeBinanceCOINMMark, dapiBaseURL, "/dapi/v1/markPriceKlines", "symbol", futuresLimit, futuresWeight, coinmMaxRange, true}
+	binanceCOINMIndex   = binanceAPI{ExchangeBinanceCOINMIndex, dapiBaseURL, "/dapi/v1/indexPriceKlines", "pair", futuresLimit, futuresWeight, coinmMaxRange, true}
+	binanceCOINMPremium = binanceAPI{ExchangeBinanceCOINMPremium, dapiBaseURL, "/dapi/v1/premiumIndexKlines", "symbol", futuresLimit, futuresWeight, coinmMaxRange, true}
+)
+
+// The spot and the futures klines of Binance
+type binanceSource struct {
+	api      binanceAPI
+	http     *httpClient
+	q        Query
+	symbol   string
+	interval Interval
+	end      int64
+	now      func() time.Time
+}
+
+// Returns the constructor of a source of the endpoint
+func newBinanceSource(api binanceAPI) func(opts *LoadOptions, intChan chan os.Signal) (Source, error) {
+	return func(opts *LoadOptions, intChan chan os.Signal) (Source, error) {
+		c, err := newHTTPClient(api.baseURL, api.path, opts, intChan)
+		if err != nil {
+			return nil, err
+		}
+		return &binanceSource{api: api, http: c, now: time.Now}, nil
+	}
+}
+
+func (s *binanceSource) Name() string {
+	return s.api.name
+}
+
+func (s *binanceSource) BatchSize() int {
+	return s.api.limit
+}
+
+func (s *binanceSource) Close() {
+	s.http.close()
+}
+
+func (s *binanceSource) Fetch(r *BatchRequest, dst []Candle) (int, error) {
+	if s.api.noSeconds && r.Interval == Interval1s {
+		return 0, errorWrap(s.api.name+" "+string(r.Interval), ErrInvalidInterval)
+	}
+	if s.api.maxRange > 0 {
+		return s.fetchWindows(r, dst)
+	}
+	// the query is built once for a symbol and a range
+	if r.Symbol != s.symbol || r.Interval != s.interval || r.End != s.end || s.q.buf == nil {
+		// the exchange compares the open time, the last candle wanted opens before the end
+		s.q.init(s.api.param, r.Symbol, r.Interval, r.End-1, s.api.limit)
+		s.symbol, s.interval, s.end = r.Symbol, r.Interval, r.End
+	}
+	return s.get(s.q.QueryStringBytes(r.Start), dst)
+}
+
+// Fetch a range wider than the exchange returns at once window by window,
+// a short window is not the end of the data
+func (s *binanceSource) fetchWindows(r *BatchRequest, dst []Candle) (int, error) {
+	end := r.End
+	if now := s.now().UnixMilli(); end <= 0 || end > now {
+		end = now
+	}
+	if r.Symbol != s.symbol || r.Interval != s.interval || s.q.buf == nil {
+		s.q.init(s.api.param, r.Symbol, r.Interval, 0, s.api.limit)
+		s.symbol, s.interval, s.end = r.Symbol, r.Interval, 0
+	}
+
+	var n int
+	start := r.Start
+	for n < len(dst) && start < end {
+		next := min(start+s.api.maxRange, end)
+		m, err := s.get(s.q.rangeBytes(start, next-1), dst[n:])
+		if err != nil {
+			return 0, err
+		}
+		n += m
+		if n == len(dst) {
+			break
+		}
+		start = next
+	}
+	return n, nil
+}
+
+func (s *binanceSource) get(query []byte, dst []Candle) (int, error) {
+	body, err := s.http.get(query, s.api.weight)
+	if err != nil {
+		return 0, err
+	}
+	n, err := parseCandles(body, dst)
+	if err != nil {
+		return 0, errorWrap("parse candles", err)
+	}
+	return n, nil
+}
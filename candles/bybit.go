@@ -0,0 +1,183 @@
+package candles
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/valyala/fastjson"
+)
+
+const (
+	bybitBaseURL    = "https://api.bybit.com"
+	bybitKlinesPath = "/v5/market/kline"
+	bybitLimit      = 1000
+	// every request counts the same
+	bybitWeight = 1
+)
+
+var errBybitFields = errors.New("parse candle: too few fields")
+
+// Bybit names of the intervals, 1s, 8h and 3d are not supported
+var bybitIntervals = map[Interval]string{
+	Interval1m:  "1",
+	Interval3m:  "3",
+	Interval5m:  "5",
+	Interval15m: "15",
+	Interval30m: "30",
+	Interval1h:  "60",
+	Interval2h:  "120",
+	Interval4h:  "240",
+	Interval6h:  "360",
+	Interval12h: "720",
+	Interval1d:  "D",
+	Interval1w:  "W",
+	Interval1M:  "M",
+}
+
+// The spot klines of Bybit. The exchange returns the latest candles of a range
+// in descending order, so the ranges are requested in windows of the limit size
+type bybitSource struct {
+	http *httpClient
+	buf  []byte
+	now  func() time.Time
+}
+
+func newBybitSource(opts *LoadOptions, intChan chan os.Signal) (Source, error) {
+	c, err := newHTTPClient(bybitBaseURL, bybitKlinesPath, opts, intChan)
+	if err != nil {
+		return nil, err
+	}
+	return &bybitSource{http: c, now: time.Now}, nil
+}
+
+func (s *bybitSource) Name() string {
+	return ExchangeBybit
+}
+
+func (s *bybitSource) BatchSize() int {
+	return bybitLimit
+}
+
+func (s *bybitSource) Close() {
+	s.http.close()
+}
+
+func (s *bybitSource) Fetch(r *BatchRequest, dst []Candle) (int, error) {
+	bi, ok := bybitIntervals[r.Interval]
+	if !ok {
+		return 0, errorWrap("bybit "+string(r.Interval), ErrInvalidInterval)
+	}
+	end := r.End
+	if now := s.now().UnixMilli(); end <= 0 || end > now {
+		end = now
+	}
+
+	var n int
+	start := r.Start
+	for n < len(dst) && start < end {
+		// the open time of the first candle after the window
+		next := start
+		for i := 0; i < bybitLimit && next < end; i++ {
+			next = r.Interval.Next(next)
+		}
+		next = min(next, end)
+
+		s.buf = append(s.buf[:0], "category=spot&symbol="...)
+		s.buf = append(s.buf, r.Symbol...)
+		s.buf = append(s.buf, "&interval="...)
+		s.buf = append(s.buf, bi...)
+		s.buf = append(s.buf, "&limit="...)
+		s.buf = strconv.AppendInt(s.buf, bybitLimit, 10)
+		s.buf = append(s.buf, "&start="...)
+		s.buf = strconv.AppendInt(s.buf, start, 10)
+		// the end is inclusive
+		s.buf = append(s.buf, "&end="...)
+		s.buf = strconv.AppendInt(s.buf, next-1, 10)
+
+		body, err := s.http.get(s.buf, bybitWeight)
+		if err != nil {
+			return 0, err
+		}
+		m, err := parseBybitCandles(body, r.Interval, dst[n:])
+		if err != nil {
+			return 0, errorWrap("parse bybit candles", err)
+		}
+		n += m
+		start = next
+	}
+	return n, nil
+}
+
+// Parse the response into dst in ascending order, the candles which do not fit are skipped
+func parseBybitCandles(b []byte, interval Interval, dst []Candle) (int, error) {
+	parser := parserPool.Get()
+	defer parserPool.Put(parser)
+	v, err := parser.ParseBytes(b)
+	if err != nil {
+		return 0, errorWrap("parse bytes", err)
+	}
+	// errors come with the 200 status
+	if code := v.GetInt("retCode"); code != 0 {
+		return 0, &APIError{Status: 200, Code: code, Msg: string(v.GetStringBytes("retMsg"))}
+	}
+	list := v.GetArray("result", "list")
+	n := min(len(list), len(dst))
+	for i := 0; i < n; i++ {
+		// the newest candle is the first one
+		c, err := list[len(list)-1-i].Array()
+		if err != nil {
+			return 0, errorWrap("parse candle", err)
+		}
+		if len(c) < 6 {
+			return 0, errBybitFields
+		}
+		if err = parseBybitCandle(c, interval, &dst[i]); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+func parseBybitCandle(c []*fastjson.Value, interval Interval, dst *Candle) error {
+	b, err := c[0].StringBytes()
+	if err != nil {
+		return errorWrap("parse open time", err)
+	}
+	t, err := strconv.ParseInt(b2s(b), 10, 64)
+	if err != nil {
+		return errorWrap("parse open time", err)
+	}
+	*dst = Candle{}
+	// Milli to seconds
+	dst.OTime = uint32(t / 1000)
+	dst.CTime = uint32(interval.Next(t) / 1000)
+
+	fields := [...]struct {
+		name string
+		dst  *float64
+	}{
+		{"open price", &dst.OPrice},
+		{"high price", &dst.HPrice},
+		{"low price", &dst.LPrice},
+		{"close price", &dst.CPrice},
+		{"volume", &dst.Volume},
+	}
+	for i, f := range fields {
+		p, err := parseFloat(c[i+1])
+		if err != nil {
+			return errorWrap("parse "+f.name, err)
+		}
+		*f.dst = p
+	}
+	if len(c) > 6 {
+		// turnover
+		p, err := parseFloat(c[6])
+		if err != nil {
+			return errorWrap("parse quote volume", err)
+		}
+		dst.QVolume = p
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+package candles
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the units of numeric times
+const (
+	TimeUnitSeconds = "s"
+	TimeUnitMilli   = "ms"
+	TimeUnitMicro   = "us"
+	TimeUnitNano    = "ns"
+)
+
+// the fields of a candle a column can be mapped to
+const (
+	csvOpenTime = iota
+	csvCloseTime
+	csvOpen
+	csvHigh
+	csvLow
+	csvClose
+	csvVolume
+	csvQVolume
+	csvTrades
+	csvTBVolume
+	csvTQVolume
+	csvFields
+)
+
+var csvFieldNames = [csvFields]string{
+	"open_time", "close_time", "open", "high", "low", "close", "volume",
+	"quote_volume", "trades", "taker_buy_volume", "taker_buy_quote_volume",
+}
+
+var (
+	ErrInvalidColumns  = errors.New("invalid csv columns")
+	ErrInvalidTimeUnit = errors.New("time unit must be one of s ms us ns")
+	ErrUnorderedRow    = errors.New("candle does not close after the previous one")
+	errCSVFields       = errors.New("too few fields")
+	errCSVCloseTime    = errors.New("close time is not after the open time")
+)
+
+// How to read candles from a CSV file
+type CSVOptions struct {
+	// the field delimiter, 0 is a comma
+	Comma rune
+	// the first line has the column names
+	Header bool
+	// a field name like open_time or close to the column index from 0,
+	// or to the column name with the header; nil is DefaultCSVColumns
+	Columns map[string]string
+	// the unit of numeric times, empty is milli seconds
+	TimeUnit string
+	// the layout of text times in UTC like 2006-01-02 15:04:05, overrides the unit
+	TimeLayout string
+}
+
+// A row which is not imported
+type CSVRejected struct {
+	Line int
+	Err  error
+}
+
+// Columns of time, open, high, low, close, volume
+func DefaultCSVColumns() map[string]string {
+	return map[string]string{
+		"open_time": "0", "open": "1", "high": "2", "low": "3", "close": "4", "volume": "5",
+	}
+}
+
+// Parse a mapping like open_time=0,open=1 or open_time=date,open=o
+func ParseCSVColumns(s string) (map[string]string, error) {
+	columns := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		name, col, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok || col == "" || csvFieldIndex(name) < 0 {
+			return nil, errorWrap(kv, ErrInvalidColumns)
+		}
+		columns[name] = col
+	}
+	return columns, nil
+}
+
+func csvFieldIndex(name string) int {
+	for i, n := range csvFieldNames {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Returns the column of every field, -1 when it is not mapped
+func csvColumnIndexes(columns map[string]string, header []string) ([csvFields]int, error) {
+	var idx [csvFields]int
+	for i := range idx {
+		idx[i] = -1
+	}
+	for name, col := range columns {
+		f := csvFieldIndex(name)
+		if f < 0 {
+			return idx, errorWrap(name, ErrInvalidColumns)
+		}
+		if n, err := strconv.Atoi(col); err == nil && n >= 0 {
+			idx[f] = n
+			continue
+		}
+		for i, h := range header {
+			if strings.TrimSpace(h) == col {
+				idx[f] = i
+			}
+		}
+		if idx[f] < 0 {
+			return idx, errorWrap(name+" column "+col+" not found", ErrInvalidColumns)
+		}
+	}
+	if idx[csvOpenTime] < 0 && idx[csvCloseTime] < 0 {
+		return idx, errorWrap("open_time or close_time is required", ErrInvalidColumns)
+	}
+	for _, f := range [...]int{csvOpen, csvHigh, csvLow, csvClose} {
+		if idx[f] < 0 {
+			return idx, errorWrap(csvFieldNames[f]+" is required", ErrInvalidColumns)
+		}
+	}
+	return idx, nil
+}
+
+// Parses the rows of a CSV file into candles
+type csvParser struct {
+	idx      [csvFields]int
+	interval Interval
+	unit     int64 // numeric times are divided by it, negative to multiply
+	layout   string
+}
+
+func newCSVParser(opts *CSVOptions, interval Interval, header []string) (*csvParser, error) {
+	columns := opts.Columns
+	if columns == nil {
+		columns = DefaultCSVColumns()
+	}
+	idx, err := csvColumnIndexes(columns, header)
+	if err != nil {
+		return nil, err
+	}
+	p := &csvParser{idx: idx, interval: interval, layout: opts.TimeLayout}
+	switch opts.TimeUnit {
+	case TimeUnitSeconds:
+		p.unit = -1000
+	case TimeUnitMilli, "":
+		p.unit = 1
+	case TimeUnitMicro:
+		p.unit = 1000
+	case TimeUnitNano:
+		p.unit = 1000000
+	default:
+		return nil, errorWrap(opts.TimeUnit, ErrInvalidTimeUnit)
+	}
+	// without an interval the close time can not be computed
+	if idx[csvCloseTime] < 0 {
+		if _, err = ParseInterval(string(interval)); err != nil {
+			return nil, errorWrap("close_time is required without the interval", ErrInvalidColumns)
+		}
+	}
+	return p, nil
+}
+
+// Returns milli seconds
+func (p *csvParser) parseTime(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	if p.layout != "" {
+		t, err := time.Parse(p.layout, s)
+		if err != nil {
+			return 0, err
+		}
+		return t.UnixMilli(), nil
+	}
+	t, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if p.unit < 0 {
+		return t * -p.unit, nil
+	}
+	return t / p.unit, nil
+}
+
+func (p *csvParser) parse(rec []string, c *Candle) error {
+	for f, col := range p.idx {
+		if col >= len(rec) {
+			return errorWrap(csvFieldNames[f], errCSVFields)
+		}
+	}
+	*c = Candle{}
+	var ot, ct int64
+	var err error
+	if col := p.idx[csvOpenTime]; col >= 0 {
+		if ot, err = p.parseTime(rec[col]); err != nil {
+			return errorWrap("parse open_time", err)
+		}
+	}
+	if col := p.idx[csvCloseTime]; col >= 0 {
+		if ct, err = p.parseTime(rec[col]); err != nil {
+			return errorWrap("parse close_time", err)
+		}
+		// the close may be the last milli second of the candle or the next open
+		ct = (ct + 999) / 1000 * 1000
+	} else {
+		ct = p.interval.Next(ot)
+	}
+	if p.idx[csvOpenTime] < 0 {
+		ot = p.interval.Prev(ct)
+	}
+	if ct <= ot {
+		return errCSVCloseTime
+	}
+	// Milli to seconds
+	c.OTime = uint32(ot / 1000)
+	c.CTime = uint32(ct / 1000)
+
+	prices := [...]*float64{
+		csvOpen:     &c.OPrice,
+		csvHigh:     &c.HPrice,
+		csvLow:      &c.LPrice,
+		csvClose:    &c.CPrice,
+		csvVolume:   &c.Volume,
+		csvQVolume:  &c.QVolume,
+		csvTBVolume: &c.TBVolume,
+		csvTQVolume: &c.TQVolume,
+	}
+	for f, dst := range prices {
+		if dst == nil || p.idx[f] < 0 {
+			continue
+		}
+		if *dst, err = strconv.ParseFloat(strings.TrimSpace(rec[p.idx[f]]), 64); err != nil {
+			return errorWrap("parse "+csvFieldNames[f], err)
+		}
+	}
+	if col := p.idx[csvTrades]; col >= 0 {
+		n, err := strconv.ParseUint(strings.TrimSpace(rec[col]), 10, 32)
+		if err != nil {
+			return errorWrap("parse trades", err)
+		}
+		c.Trades = uint32(n)
+	}
+	return nil
+}
+
+// Append the candles of a CSV file to the storage. The rows which can not be
+// parsed or do not close after the previous candle, and the last stored one,
+// are rejected and the import goes on
+func ImportCSV(stg *Storage, r io.Reader, opts *CSVOptions) (int64, []CSVRejected, error) {
+	cr := csv.NewReader(r)
+	if opts.Comma != 0 {
+		cr.Comma = opts.Comma
+	}
+	cr.ReuseRecord = true
+	cr.FieldsPerRecord = -1
+
+	var header []string
+	if opts.Header {
+		rec, err := cr.Read()
+		if err != nil {
+			return 0, nil, errorWrap("read header", err)
+		}
+		header = append(header, rec...)
+	}
+	p, err := newCSVParser(opts, stg.Header().Interval, header)
+	if err != nil {
+		return 0, nil, err
+	}
+	last, err := stg.LastCandleCloseTime()
+	if err != nil {
+		return 0, nil, errorWrap("read last close time", err)
+	}
+
+	var total int64
+	var rejected []CSVRejected
+	cs := make([]Candle, 0, archiveBatch)
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// a broken quote, the reader goes on from the next line
+			var pe *csv.ParseError
+			if errors.As(err, &pe) {
+				rejected = append(rejected, CSVRejected{Line: pe.StartLine, Err: pe.Err})
+				continue
+			}
+			return total, rejected, err
+		}
+		// a quoted field may take many lines
+		line, _ := cr.FieldPos(0)
+		var c Candle
+		if err = p.parse(rec, &c); err != nil {
+			rejected = append(rejected, CSVRejected{Line: line, Err: err})
+			continue
+		}
+		if SecToMilli(c.CTime) <= last {
+			rejected = append(rejected, CSVRejected{Line: line, Err: ErrUnorderedRow})
+			continue
+		}
+		last = SecToMilli(c.CTime)
+		cs = append(cs, c)
+		if len(cs) == cap(cs) {
+			if err = stg.Save(cs); err != nil {
+				return total, rejected, errorWrap("save candles", err)
+			}
+			total += int64(len(cs))
+			cs = cs[:0]
+		}
+	}
+	if err = stg.Save(cs); err != nil {
+		return total, rejected, errorWrap("save candles", err)
+	}
+	return total + int64(len(cs)), rejected, nil
+}
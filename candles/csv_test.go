@@ -0,0 +1,86 @@
+package candles
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseCSVColumns(t *testing.T) {
+	columns, err := ParseCSVColumns("open_time=date, close=c,open=1,high=2,low=3")
+	if err != nil {
+		t.Fatalf("parse columns: %s", err.Error())
+	}
+	if len(columns) != 5 || columns["open_time"] != "date" || columns["close"] != "c" {
+		t.Errorf("columns: got %v", columns)
+	}
+	if _, err = ParseCSVColumns("bid=1"); !errors.Is(err, ErrInvalidColumns) {
+		t.Errorf("want error '%s', got '%v'", ErrInvalidColumns, err)
+	}
+}
+
+func TestImportCSV(t *testing.T) {
+	stg, err := NewFileStorage(filepath.Join(t.TempDir(), "BTCUSDT-1m.bin"), Header{
+		Format:   Format{Layout: LayoutExtended, Encoding: EncodingFloat64},
+		Symbol:   "BTCUSDT",
+		Interval: Interval1m,
+	})
+	if err != nil {
+		t.Fatalf("create new storage: %s", err.Error())
+	}
+	defer stg.Close()
+
+	rows := "date;o;h;l;c;v;n\n" +
+		"2024-02-19 00:00:00;1.1;1.3;1.0;1.2;10.5;3\n" +
+		"2024-02-19 00:01:00;1.2;1.4;1.1;1.3;x;4\n" +
+		"2024-02-19 00:03:00;1.3;1.5;1.2;1.4;30.5;5\n" +
+		"2024-02-19 00:02:00;1.3;1.5;1.2;1.4;30.5;5\n" +
+		"2024-02-19 00:04:00;1.4\n"
+	columns, _ := ParseCSVColumns("open_time=date,open=o,high=h,low=l,close=c,volume=v,trades=n")
+	opts := &CSVOptions{Comma: ';', Header: true, Columns: columns, TimeLayout: "2006-01-02 15:04:05"}
+	n, rejected, err := ImportCSV(stg, strings.NewReader(rows), opts)
+	if err != nil {
+		t.Fatalf("import csv: %s", err.Error())
+	}
+	if n != 2 {
+		t.Errorf("imported candles: want 2, got %d", n)
+	}
+	wantLines := []int{3, 5, 6}
+	if len(rejected) != len(wantLines) {
+		t.Fatalf("rejected rows: want lines %v, got %v", wantLines, rejected)
+	}
+	for i, r := range rejected {
+		if r.Line != wantLines[i] {
+			t.Errorf("rejected row: want line %d, got %d", wantLines[i], r.Line)
+		}
+	}
+	if !errors.Is(rejected[1].Err, ErrUnorderedRow) {
+		t.Errorf("unordered row: want error '%s', got '%v'", ErrUnorderedRow, rejected[1].Err)
+	}
+
+	cs, err := stg.ReadAll()
+	if err != nil {
+		t.Fatalf("read all candles: %s", err.Error())
+	}
+	want := Candle{1.3, 1.5, 1.2, 1.4, 30.5, 1708300980, 1708301040, 0, 5, 0, 0}
+	if len(cs) != 2 || cs[1] != want {
+		t.Errorf("candles: want the last %#v, got %#v", want, cs)
+	}
+
+	// close times in seconds without a header
+	opts = &CSVOptions{Columns: map[string]string{"close_time": "0", "open": "1", "high": "1", "low": "1", "close": "1"}, TimeUnit: TimeUnitSeconds}
+	n, rejected, err = ImportCSV(stg, strings.NewReader("1708301100,2.0\n"), opts)
+	if err != nil || n != 1 || len(rejected) != 0 {
+		t.Fatalf("import close times: want 1 candle, got %d, %v, %v", n, rejected, err)
+	}
+	cs, _ = stg.ReadAll()
+	if c := cs[len(cs)-1]; c.OTime != 1708301040 || c.CTime != 1708301100 {
+		t.Errorf("close time: want 1708301040-1708301100, got %d-%d", c.OTime, c.CTime)
+	}
+
+	opts.TimeUnit = "h"
+	if _, _, err = ImportCSV(stg, strings.NewReader(""), opts); !errors.Is(err, ErrInvalidTimeUnit) {
+		t.Errorf("want error '%s', got '%v'", ErrInvalidTimeUnit, err)
+	}
+}
@@ -0,0 +1,90 @@
+package candles
+
+import (
+	"errors"
+	"io"
+)
+
+var ErrCursorRange = errors.New("cursor position is out of range")
+
+// Reads the candles of a storage from its own position, so several cursors
+// of one storage do not move each other. A cursor is used by one goroutine,
+// the cursors of a storage may be used by different ones.
+// The position is the index of the candle read next by Next, it is between
+// 0 and the number of candles
+type Cursor struct {
+	s   *Storage
+	pos int64
+	buf []byte
+}
+
+// Returns a cursor at the first candle
+func (s *Storage) NewCursor() *Cursor {
+	return &Cursor{s: s}
+}
+
+// Returns the index of the candle read next by Next
+func (c *Cursor) Pos() int64 {
+	return c.pos
+}
+
+// Move the position to the index i, the number of candles is the end
+func (c *Cursor) SeekIndex(i int64) error {
+	n, err := c.s.SizeCandles()
+	if err != nil {
+		return err
+	}
+	if i < 0 || i > n {
+		return ErrCursorRange
+	}
+	c.pos = i
+	return nil
+}
+
+// Move the position to the end, Prev reads from the last candle then
+func (c *Cursor) SeekEnd() error {
+	n, err := c.s.SizeCandles()
+	if err != nil {
+		return err
+	}
+	c.pos = n
+	return nil
+}
+
+// Move the position to the first candle closed later than t (milli seconds),
+// the candles are expected to be sorted. Returns the new position
+func (c *Cursor) SeekTime(t int64) (int64, error) {
+	i, err := c.s.searchTime(t)
+	if err != nil {
+		return 0, err
+	}
+	c.pos = i
+	return i, nil
+}
+
+// Read the candles from the position forward and move it after them.
+// Returns the number of candles read, io.EOF when there are no more of them
+func (c *Cursor) Next(cs []Candle) (int, error) {
+	n, err := c.s.readAtBuf(cs, c.pos*int64(c.s.size), &c.buf)
+	c.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// Read the candles before the position and move it to the first of them,
+// the candles are in the file order and the last one is just before the
+// previous position. Returns the number of candles read, io.EOF at the start
+func (c *Cursor) Prev(cs []Candle) (int, error) {
+	if c.pos == 0 {
+		return 0, io.EOF
+	}
+	start := max(c.pos-int64(len(cs)), 0)
+	n, err := c.s.readAtBuf(cs[:c.pos-start], start*int64(c.s.size), &c.buf)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	c.pos = start
+	return n, nil
+}
@@ -0,0 +1,89 @@
+package candles
+
+import (
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	stg, err := NewFileStorage(filepath.Join(t.TempDir(), "BTCUSDT-1m.bin"), Header{})
+	if err != nil {
+		t.Fatalf("create new storage: %s", err.Error())
+	}
+	defer stg.Close()
+	cs := make([]Candle, 10)
+	for i := range cs {
+		f := float64(i)
+		cs[i] = Candle{f, f, f, f, f, uint32(i * 60), uint32(i*60 + 60), 0, 0, 0, 0}
+	}
+	if err = stg.Save(cs); err != nil {
+		t.Fatalf("save candles: %s", err.Error())
+	}
+
+	// the cursors do not move each other
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cur := stg.NewCursor()
+			buf := make([]Candle, 3)
+			var got []Candle
+			for {
+				n, err := cur.Next(buf)
+				got = append(got, buf[:n]...)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Errorf("next: %s", err.Error())
+					return
+				}
+			}
+			if len(got) != len(cs) || got[9] != cs[9] {
+				t.Errorf("forward: want %d candles, got %d", len(cs), len(got))
+			}
+		}()
+	}
+	wg.Wait()
+
+	cur := stg.NewCursor()
+	if err = cur.SeekEnd(); err != nil {
+		t.Fatalf("seek end: %s", err.Error())
+	}
+	buf := make([]Candle, 4)
+	want := []int{4, 4, 2}
+	for k := 0; ; k++ {
+		n, err := cur.Prev(buf)
+		if err == io.EOF {
+			if k != len(want) {
+				t.Errorf("reverse: want %d batches, got %d", len(want), k)
+			}
+			break
+		}
+		if err != nil || n != want[k] {
+			t.Fatalf("prev %d: want %d candles, got %d %v", k, want[k], n, err)
+		}
+		// the last candle of the batch is just before the new position plus n
+		if buf[n-1] != cs[cur.Pos()+int64(n)-1] {
+			t.Errorf("prev %d: want %v, got %v", k, cs[cur.Pos()+int64(n)-1], buf[n-1])
+		}
+	}
+
+	if err = cur.SeekIndex(11); err != ErrCursorRange {
+		t.Errorf("seek index: want error '%s', got '%v'", ErrCursorRange, err)
+	}
+	if err = cur.SeekIndex(7); err != nil {
+		t.Fatalf("seek index: %s", err.Error())
+	}
+	if n, _ := cur.Next(buf[:1]); n != 1 || buf[0] != cs[7] {
+		t.Errorf("next after seek: want %v, got %v", cs[7], buf[0])
+	}
+	// the candle 3 closes at 240 seconds
+	i, err := cur.SeekTime(240000)
+	if err != nil || i != 4 {
+		t.Errorf("seek time: want 4, got %d %v", i, err)
+	}
+}
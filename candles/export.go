@@ -0,0 +1,272 @@
+package candles
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+)
+
+// the time format of RFC 3339 in UTC without fractions, the candle times are
+// whole seconds, the units of TimeUnitSeconds and TimeUnitMilli are unix times
+const TimeFormatRFC3339 = "rfc3339"
+
+var ErrInvalidTimeFormat = errors.New("time format must be one of s ms rfc3339")
+
+// Writes candles in some file format
+type CandleWriter interface {
+	// Write the candles after the previous ones
+	WriteCandles(cs []Candle) error
+	// Write what is buffered and the end of the file format if it has one,
+	// the underlying writer is not closed
+	Close() error
+}
+
+// What to write, the columns are the names of the fields of ParseCSVColumns
+type ExportOptions struct {
+	// nil is all the fields of the layout
+	Columns []string
+	// one of s ms rfc3339, empty is milli seconds
+	TimeFormat string
+	// milli seconds, the candles open not earlier than From and close
+	// not later than To, 0 means no limit
+	From int64
+	To   int64
+	// rows of a Parquet row group, 0 is DefaultParquetRowGroup
+	RowGroupRows int
+}
+
+// Returns the names of the fields stored in the layout
+func LayoutColumns(l Layout) []string {
+	if l == LayoutExtended {
+		return csvFieldNames[:]
+	}
+	return csvFieldNames[:csvQVolume]
+}
+
+// Check the columns and the time format before the output is created,
+// the writers return the same errors
+func ValidateExportOptions(h Header, opts *ExportOptions) error {
+	_, err := newRowEncoder(h, opts)
+	return err
+}
+
+// Converts the fields of a candle to text
+type rowEncoder struct {
+	fields  []int
+	names   []string
+	timeFmt string
+	// float32 values are printed as short as they were parsed
+	bitSize int
+}
+
+func newRowEncoder(h Header, opts *ExportOptions) (*rowEncoder, error) {
+	names := opts.Columns
+	if names == nil {
+		names = LayoutColumns(h.Format.Layout)
+	}
+	stored := LayoutColumns(h.Format.Layout)
+	e := &rowEncoder{names: names, timeFmt: opts.TimeFormat, bitSize: 64}
+	for _, name := range names {
+		f := csvFieldIndex(name)
+		if f < 0 {
+			return nil, errorWrap(name, ErrInvalidColumns)
+		}
+		if f >= len(stored) {
+			return nil, errorWrap(name+" is not stored", ErrInvalidColumns)
+		}
+		e.fields = append(e.fields, f)
+	}
+	switch e.timeFmt {
+	case "":
+		e.timeFmt = TimeUnitMilli
+	case TimeUnitSeconds, TimeUnitMilli, TimeFormatRFC3339:
+	default:
+		return nil, errorWrap(opts.TimeFormat, ErrInvalidTimeFormat)
+	}
+	if h.Format.Encoding == EncodingFloat32 {
+		e.bitSize = 32
+	}
+	return e, nil
+}
+
+// Append the value of the field, the text times are quoted if quote is set
+func (e *rowEncoder) appendField(b []byte, c *Candle, f int, quote bool) []byte {
+	switch f {
+	case csvOpenTime:
+		return e.appendTime(b, c.OTime, quote)
+	case csvCloseTime:
+		return e.appendTime(b, c.CTime, quote)
+	case csvTrades:
+		return strconv.AppendUint(b, uint64(c.Trades), 10)
+	}
+	return strconv.AppendFloat(b, candleFloat(c, f), 'f', -1, e.bitSize)
+}
+
+// Returns the float field of the candle
+func candleFloat(c *Candle, f int) float64 {
+	switch f {
+	case csvOpen:
+		return c.OPrice
+	case csvHigh:
+		return c.HPrice
+	case csvLow:
+		return c.LPrice
+	case csvClose:
+		return c.CPrice
+	case csvVolume:
+		return c.Volume
+	case csvQVolume:
+		return c.QVolume
+	case csvTBVolume:
+		return c.TBVolume
+	case csvTQVolume:
+		return c.TQVolume
+	}
+	return 0
+}
+
+// The close time is the open time of the next candle
+func (e *rowEncoder) appendTime(b []byte, t uint32, quote bool) []byte {
+	switch e.timeFmt {
+	case TimeUnitSeconds:
+		return strconv.AppendUint(b, uint64(t), 10)
+	case TimeFormatRFC3339:
+		if quote {
+			b = append(b, '"')
+		}
+		b = time.Unix(int64(t), 0).UTC().AppendFormat(b, time.RFC3339)
+		if quote {
+			b = append(b, '"')
+		}
+		return b
+	}
+	return strconv.AppendInt(b, SecToMilli(t), 10)
+}
+
+// Writes a header line with the column names and a line per candle
+type csvWriter struct {
+	e   *rowEncoder
+	w   *bufio.Writer
+	buf []byte
+}
+
+func NewCSVWriter(w io.Writer, h Header, opts *ExportOptions) (CandleWriter, error) {
+	e, err := newRowEncoder(h, opts)
+	if err != nil {
+		return nil, err
+	}
+	cw := &csvWriter{e: e, w: bufio.NewWriter(w)}
+	for i, name := range e.names {
+		if i > 0 {
+			cw.buf = append(cw.buf, ',')
+		}
+		cw.buf = append(cw.buf, name...)
+	}
+	cw.buf = append(cw.buf, '\n')
+	if _, err = cw.w.Write(cw.buf); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (w *csvWriter) WriteCandles(cs []Candle) error {
+	for i := range cs {
+		w.buf = w.buf[:0]
+		for j, f := range w.e.fields {
+			if j > 0 {
+				w.buf = append(w.buf, ',')
+			}
+			w.buf = w.e.appendField(w.buf, &cs[i], f, false)
+		}
+		w.buf = append(w.buf, '\n')
+		if _, err := w.w.Write(w.buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *csvWriter) Close() error {
+	return w.w.Flush()
+}
+
+// Writes a JSON object per line
+type jsonlWriter struct {
+	e   *rowEncoder
+	w   *bufio.Writer
+	buf []byte
+}
+
+func NewJSONLWriter(w io.Writer, h Header, opts *ExportOptions) (CandleWriter, error) {
+	e, err := newRowEncoder(h, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonlWriter{e: e, w: bufio.NewWriter(w)}, nil
+}
+
+func (w *jsonlWriter) WriteCandles(cs []Candle) error {
+	for i := range cs {
+		w.buf = append(w.buf[:0], '{')
+		for j, f := range w.e.fields {
+			if j > 0 {
+				w.buf = append(w.buf, ',')
+			}
+			// the names do not need escaping
+			w.buf = append(w.buf, '"')
+			w.buf = append(w.buf, w.e.names[j]...)
+			w.buf = append(w.buf, '"', ':')
+			w.buf = w.e.appendField(w.buf, &cs[i], f, true)
+		}
+		w.buf = append(w.buf, '}', '\n')
+		if _, err := w.w.Write(w.buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *jsonlWriter) Close() error {
+	return w.w.Flush()
+}
+
+// Stream the candles of the range to the writer in batches, the storage is
+// expected to be sorted, only the candles of the range are read.
+// The writer is not closed
+func Export(stg *Storage, w CandleWriter, from, to int64) (int64, error) {
+	var total int64
+	// the candles closed not later than the start are skipped
+	first, err := stg.searchTime(from)
+	if err != nil {
+		return 0, err
+	}
+	pos := first * int64(stg.size)
+	cs := make([]Candle, scanBatch)
+	for {
+		n, err := stg.readAt(cs, pos)
+		if err != nil && err != io.EOF {
+			return total, err
+		}
+		pos += int64(n * stg.size)
+
+		batch := cs[:n]
+		for len(batch) > 0 && from > 0 && SecToMilli(batch[0].OTime) < from {
+			batch = batch[1:]
+		}
+		done := n < len(cs)
+		if to > 0 {
+			m := cutAfter(batch, to)
+			done = done || m < len(batch)
+			batch = batch[:m]
+		}
+		if err = w.WriteCandles(batch); err != nil {
+			return total, errorWrap("write candles", err)
+		}
+		total += int64(len(batch))
+		if done {
+			return total, nil
+		}
+	}
+}
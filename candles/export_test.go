@@ -0,0 +1,71 @@
+package candles
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	h := Header{Symbol: "BTCUSDT", Interval: Interval1m}
+	stg, err := NewFileStorage(filepath.Join(t.TempDir(), "BTCUSDT-1m.bin"), h)
+	if err != nil {
+		t.Fatalf("create new storage: %s", err.Error())
+	}
+	defer stg.Close()
+	cs := []Candle{
+		{1.1, 1.3, 1.0, 1.2, 10.5, 1708300800, 1708300860, 0, 0, 0, 0},
+		{1.2, 1.4, 1.1, 1.3, 20.5, 1708300860, 1708300920, 0, 0, 0, 0},
+		{1.3, 1.5, 1.2, 1.4, 30.5, 1708300920, 1708300980, 0, 0, 0, 0},
+	}
+	if err = stg.Save(cs); err != nil {
+		t.Fatalf("save candles: %s", err.Error())
+	}
+
+	var b bytes.Buffer
+	w, err := NewCSVWriter(&b, stg.Header(), &ExportOptions{})
+	if err != nil {
+		t.Fatalf("new csv writer: %s", err.Error())
+	}
+	n, err := Export(stg, w, 0, 0)
+	if err != nil || w.Close() != nil {
+		t.Fatalf("export csv: %v", err)
+	}
+	want := "open_time,close_time,open,high,low,close,volume\n" +
+		"1708300800000,1708300860000,1.1,1.3,1,1.2,10.5\n" +
+		"1708300860000,1708300920000,1.2,1.4,1.1,1.3,20.5\n" +
+		"1708300920000,1708300980000,1.3,1.5,1.2,1.4,30.5\n"
+	if n != 3 || b.String() != want {
+		t.Errorf("export csv: want 3 candles\n%s, got %d\n%s", want, n, b.String())
+	}
+
+	b.Reset()
+	opts := &ExportOptions{Columns: []string{"open_time", "close"}, TimeFormat: TimeFormatRFC3339}
+	w, err = NewJSONLWriter(&b, stg.Header(), opts)
+	if err != nil {
+		t.Fatalf("new jsonl writer: %s", err.Error())
+	}
+	// only the middle candle is in the range
+	n, err = Export(stg, w, 1708300860000, 1708300920000)
+	if err != nil || w.Close() != nil {
+		t.Fatalf("export jsonl: %v", err)
+	}
+	want = `{"open_time":"2024-02-19T00:01:00Z","close":1.3}` + "\n"
+	if n != 1 || b.String() != want {
+		t.Errorf("export jsonl: want 1 candle %s, got %d %s", want, n, b.String())
+	}
+
+	if _, err = NewCSVWriter(&b, stg.Header(), &ExportOptions{Columns: []string{"trades"}}); !errors.Is(err, ErrInvalidColumns) {
+		t.Errorf("not stored column: want error '%s', got '%v'", ErrInvalidColumns, err)
+	}
+	if _, err = NewCSVWriter(&b, stg.Header(), &ExportOptions{TimeFormat: "us"}); !errors.Is(err, ErrInvalidTimeFormat) {
+		t.Errorf("time format: want error '%s', got '%v'", ErrInvalidTimeFormat, err)
+	}
+	if err = ValidateExportOptions(stg.Header(), &ExportOptions{Columns: []string{"trades"}}); !errors.Is(err, ErrInvalidColumns) {
+		t.Errorf("validate not stored column: want error '%s', got '%v'", ErrInvalidColumns, err)
+	}
+	if err = ValidateExportOptions(stg.Header(), &ExportOptions{Columns: []string{"open_time", "close"}}); err != nil {
+		t.Errorf("validate columns: %s", err.Error())
+	}
+}
@@ -0,0 +1,260 @@
+package candles
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// candles scanned or merged at once
+const scanBatch = 4096
+
+var ErrUnorderedCandles = errors.New("candles file is not sorted by close time")
+
+// A range of missing candles
+type Gap struct {
+	From    int64 // open time as milli seconds of the first missing candle
+	To      int64 // open time as milli seconds of the candle after the gap
+	Missing int64 // number of missing candles
+}
+
+// Scan the data file for candles which do not follow the previous one at the interval.
+// Candles which close not later than the previous one are counted as unordered
+func FindGaps(stg *Storage, interval Interval) ([]Gap, int64, error) {
+	var gaps []Gap
+	var unordered int64
+	var prev uint32
+	var pos int64
+	cs := make([]Candle, scanBatch)
+	for {
+		n, err := stg.readAt(cs, pos)
+		if err != nil && err != io.EOF {
+			return nil, 0, err
+		}
+		pos += int64(n * stg.size)
+		for i := 0; i < n; i++ {
+			c := cs[i].CTime
+			if prev == 0 {
+				prev = c
+				continue
+			}
+			if c <= prev {
+				unordered++
+				continue
+			}
+			want := interval.Next(SecToMilli(prev))
+			if got := SecToMilli(c); got > want {
+				g := Gap{From: SecToMilli(prev), To: interval.Prev(got)}
+				for t := g.From; t < g.To; t = interval.Next(t) {
+					g.Missing++
+				}
+				gaps = append(gaps, g)
+			}
+			prev = c
+		}
+		if n < len(cs) {
+			return gaps, unordered, nil
+		}
+	}
+}
+
+// Download the missing candles of the gaps and rebuild the data file in sorted order.
+// Gaps the exchange has no data for stay as they are. The downloaded candles are
+// kept in a temporary file next to the data file, so a gap of any size takes
+// only the memory of the batches. A file with candles which close before the
+// previous one is not rebuilt. Returns the number of downloaded candles
+func RepairGaps(stg *Storage, gaps []Gap, intChan chan os.Signal, opts *LoadOptions) (int, error) {
+	src, err := NewSource(opts, intChan)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	found, err := tempStorage(stg.fd.Name(), *stg.header)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(found.fd.Name())
+	defer found.Close()
+
+	var total int
+	cs := make([]Candle, src.BatchSize())
+	for _, g := range gaps {
+		r := &BatchRequest{Symbol: opts.Symbol, Interval: opts.Interval, Start: g.From, End: g.To}
+		for r.Start < g.To {
+			n, err := src.Fetch(r, cs)
+			if err != nil {
+				return 0, err
+			}
+			full := n == len(cs)
+			for i := 0; i < n; i++ {
+				if SecToMilli(cs[i].OTime) >= g.To {
+					// the candle after the gap is already stored
+					n, full = i, false
+					break
+				}
+			}
+			if err = found.Save(cs[:n]); err != nil {
+				return 0, errorWrap("save downloaded candles", err)
+			}
+			total += n
+			if !full || n == 0 {
+				break
+			}
+			r.Start = SecToMilli(cs[n-1].CTime)
+
+			select {
+			case <-intChan:
+				return 0, ErrInterrupted
+			default:
+			}
+		}
+	}
+	if total == 0 {
+		return 0, nil
+	}
+	if err := stg.merge(found); err != nil {
+		return 0, errorWrap("rebuild storage", err)
+	}
+	return total, nil
+}
+
+// Create an empty data file with the header next to the file at path,
+// the caller removes it
+func tempStorage(path string, h Header) (*Storage, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	stg, err := NewFileStorage(tmpPath, h)
+	if err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	return stg, nil
+}
+
+// Reads the candles of a storage one by one in batches
+type candleScanner struct {
+	cur  *Cursor
+	buf  []Candle
+	i, n int
+}
+
+func newCandleScanner(s *Storage) *candleScanner {
+	return &candleScanner{cur: s.NewCursor(), buf: make([]Candle, scanBatch)}
+}
+
+// Returns the next candle, nil after the last one
+func (r *candleScanner) next() (*Candle, error) {
+	if r.i == r.n {
+		n, err := r.cur.Next(r.buf)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		r.i, r.n = 0, n
+		if n == 0 {
+			return nil, nil
+		}
+	}
+	r.i++
+	return &r.buf[r.i-1], nil
+}
+
+// Write the stored candles and the sorted candles of found to a new file which
+// then replaces the current one. Candles which close at the same time as the
+// previous written one are duplicates and are dropped, a candle which closes
+// before it fails the merge and the current file is kept
+func (s *Storage) merge(found *Storage) error {
+	path := s.fd.Name()
+	dst, err := tempStorage(path, *s.header)
+	if err != nil {
+		return err
+	}
+	tmpPath := dst.fd.Name()
+	defer os.Remove(tmpPath) // there is nothing to remove after the rename
+	defer dst.Close()
+
+	var last uint32
+	out := make([]Candle, 0, scanBatch)
+	add := func(c *Candle) error {
+		if c.CTime == last {
+			return nil
+		}
+		if c.CTime < last {
+			return ErrUnorderedCandles
+		}
+		last = c.CTime
+		out = append(out, *c)
+		if len(out) < cap(out) {
+			return nil
+		}
+		err := dst.Save(out)
+		out = out[:0]
+		return err
+	}
+
+	stored, downloaded := newCandleScanner(s), newCandleScanner(found)
+	a, err := stored.next()
+	if err != nil {
+		return err
+	}
+	b, err := downloaded.next()
+	if err != nil {
+		return err
+	}
+	for a != nil || b != nil {
+		if b != nil && (a == nil || b.CTime < a.CTime) {
+			if err = add(b); err != nil {
+				return err
+			}
+			b, err = downloaded.next()
+		} else {
+			if err = add(a); err != nil {
+				return err
+			}
+			a, err = stored.next()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err = dst.Save(out); err != nil {
+		return err
+	}
+	if err = dst.Sync(); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmpPath, DefaultFilePerm); err != nil {
+		return err
+	}
+	if err = os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	if err = syncDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	return s.reopen(path)
+}
+
+// Switch to the file at path after it was replaced
+func (s *Storage) reopen(path string) error {
+	fd, err := os.OpenFile(path, flagAppend, DefaultFilePerm)
+	if err != nil {
+		return err
+	}
+	h, err := readHeader(fd)
+	if err != nil {
+		fd.Close()
+		return err
+	}
+	s.fd.Close()
+	s.fd = fd
+	s.header = h
+	s.offset = HeaderByteSize
+	s.readPos = 0
+	return nil
+}
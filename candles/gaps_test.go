@@ -0,0 +1,181 @@
+package candles
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+const testGapsFile = "/tmp/test-candles-gaps.bin"
+
+func testCandle(ctime uint32) Candle {
+	return Candle{OPrice: 1, HPrice: 1, LPrice: 1, CPrice: 1, Volume: 1, OTime: ctime - 1, CTime: ctime}
+}
+
+func TestFindGaps(t *testing.T) {
+	stg, err := NewFileStorage(testGapsFile, Header{Interval: Interval1s})
+	if err != nil {
+		t.Fatalf("create new storage: %s", err.Error())
+	}
+	defer stg.Close()
+
+	cs := []Candle{testCandle(61), testCandle(62), testCandle(65), testCandle(65), testCandle(66)}
+	if err = stg.Save(cs); err != nil {
+		t.Fatalf("save candles: %s", err.Error())
+	}
+
+	gaps, unordered, err := FindGaps(stg, Interval1s)
+	if err != nil {
+		t.Fatalf("find gaps: %s", err.Error())
+	}
+	want := Gap{From: 62000, To: 64000, Missing: 2}
+	if len(gaps) != 1 || gaps[0] != want {
+		t.Errorf("gaps: want %#v, got %#v", want, gaps)
+	}
+	if unordered != 1 {
+		t.Errorf("unordered candles: want 1, got %d", unordered)
+	}
+
+	// fill the gap as a repair does after the download
+	found, err := tempStorage(testGapsFile, Header{Interval: Interval1s})
+	if err != nil {
+		t.Fatalf("create temporary storage: %s", err.Error())
+	}
+	defer os.Remove(found.fd.Name())
+	defer found.Close()
+	if err = found.Save([]Candle{testCandle(63), testCandle(64)}); err != nil {
+		t.Fatalf("save downloaded candles: %s", err.Error())
+	}
+	if err = stg.merge(found); err != nil {
+		t.Fatalf("merge candles: %s", err.Error())
+	}
+	gaps, unordered, err = FindGaps(stg, Interval1s)
+	if err != nil {
+		t.Fatalf("find gaps after merge: %s", err.Error())
+	}
+	if len(gaps) != 0 || unordered != 0 {
+		t.Errorf("after merge: want no gaps, got %#v and %d unordered", gaps, unordered)
+	}
+
+	all, err := stg.ReadAll()
+	if err != nil {
+		t.Fatalf("read all candles: %s", err.Error())
+	}
+	if len(all) != 6 || all[0].CTime != 61 || all[5].CTime != 66 {
+		t.Errorf("merged candles: want 61..66, got %#v", all)
+	}
+}
+
+func TestMergeUnordered(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "BTCUSDT-1s.bin")
+	stg, err := NewFileStorage(path, Header{Interval: Interval1s})
+	if err != nil {
+		t.Fatalf("create new storage: %s", err.Error())
+	}
+	defer stg.Close()
+	// 64 is stored after 65
+	cs := []Candle{testCandle(61), testCandle(62), testCandle(65), testCandle(64), testCandle(66)}
+	if err = stg.Save(cs); err != nil {
+		t.Fatalf("save candles: %s", err.Error())
+	}
+
+	found, err := tempStorage(path, Header{Interval: Interval1s})
+	if err != nil {
+		t.Fatalf("create temporary storage: %s", err.Error())
+	}
+	defer os.Remove(found.fd.Name())
+	defer found.Close()
+	if err = found.Save([]Candle{testCandle(63)}); err != nil {
+		t.Fatalf("save downloaded candles: %s", err.Error())
+	}
+	if err = stg.merge(found); !errors.Is(err, ErrUnorderedCandles) {
+		t.Fatalf("merge unordered candles: want %v, got %v", ErrUnorderedCandles, err)
+	}
+
+	// the stored candles are kept as they are
+	all, err := stg.ReadAll()
+	if err != nil || len(all) != len(cs) {
+		t.Fatalf("read after merge: want %d candles, got %d %v", len(cs), len(all), err)
+	}
+	for i := range cs {
+		if all[i] != cs[i] {
+			t.Errorf("candle %d: want %v, got %v", i, cs[i], all[i])
+		}
+	}
+	if files, _ := filepath.Glob(path + ".tmp-*"); len(files) != 1 {
+		t.Errorf("temporary files: want only the downloaded one, got %v", files)
+	}
+}
+
+func TestRepairGaps(t *testing.T) {
+	opts := testLoadOptions()
+	var calls int
+	testServer(t, opts, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		start, err := strconv.ParseInt(r.URL.Query().Get("startTime"), 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// the exchange returns the candles after the gap too
+		fmt.Fprint(w, "[")
+		for i := int64(0); i < 4; i++ {
+			if i > 0 {
+				fmt.Fprint(w, ",")
+			}
+			ot := start + i*60000
+			fmt.Fprintf(w, `[%d,"2","2","2","2","2",%d,"0",0,"0","0","0"]`, ot, ot+59999)
+		}
+		fmt.Fprint(w, "]")
+	})
+
+	path := filepath.Join(t.TempDir(), "BTCUSDT-1m.bin")
+	stg, err := NewFileStorage(path, Header{Symbol: "BTCUSDT", Interval: Interval1m, Exchange: DefaultExchange})
+	if err != nil {
+		t.Fatalf("create new storage: %s", err.Error())
+	}
+	defer stg.Close()
+	// 2024-02-19 00:00:00, two minutes are missing after the second candle
+	var start uint32 = 1708300800
+	cs := []Candle{}
+	for _, m := range []uint32{0, 1, 4, 5} {
+		c := testCandle(start + (m+1)*60)
+		c.OTime = start + m*60
+		cs = append(cs, c)
+	}
+	if err = stg.Save(cs); err != nil {
+		t.Fatalf("save candles: %s", err.Error())
+	}
+	gaps, _, err := FindGaps(stg, Interval1m)
+	if err != nil || len(gaps) != 1 || gaps[0].Missing != 2 {
+		t.Fatalf("find gaps: want 1 gap of 2 candles, got %#v %v", gaps, err)
+	}
+
+	n, err := RepairGaps(stg, gaps, make(chan os.Signal, 1), opts)
+	if err != nil {
+		t.Fatalf("repair gaps: %s", err.Error())
+	}
+	if n != 2 || calls != 1 {
+		t.Errorf("repair gaps: want 2 candles in 1 call, got %d in %d", n, calls)
+	}
+	all, err := stg.ReadAll()
+	if err != nil || len(all) != 6 {
+		t.Fatalf("read repaired: want 6 candles, got %d %v", len(all), err)
+	}
+	for i, c := range all {
+		if c.OTime != start+uint32(i*60) {
+			t.Errorf("candle %d: want open time %d, got %d", i, start+uint32(i*60), c.OTime)
+		}
+	}
+	if all[2].CPrice != 2 || all[4].CPrice != 1 {
+		t.Errorf("repaired candles: want the downloaded close 2 and the stored 1, got %v %v", all[2], all[4])
+	}
+	// the temporary files are removed
+	if files, _ := filepath.Glob(path + ".tmp-*"); len(files) != 0 {
+		t.Errorf("temporary files: want none, got %v", files)
+	}
+}
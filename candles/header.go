@@ -0,0 +1,134 @@
+package candles
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+)
+
+const (
+	// bytes at the start of every data file
+	headerMagic    = "CNDL"
+	headerVersion  = 1
+	HeaderByteSize = 128
+
+	DefaultExchange = ExchangeBinance
+)
+
+// offsets of the header fields, strings are padded with zero bytes
+const (
+	hdrMagic     = 0  // 4 bytes
+	hdrVersion   = 4  // 1 byte
+	hdrLayout    = 5  // 1 byte
+	hdrEncoding  = 6  // 1 byte, 1 byte reserved after
+	hdrCreatedAt = 8  // 8 bytes, milli seconds
+	hdrInterval  = 16 // 16 bytes
+	hdrExchange  = 32 // 32 bytes
+	hdrSymbol    = 64 // 32 bytes, the rest is reserved
+	hdrEnd       = 96
+)
+
+var (
+	ErrUnknownFormat      = errors.New("unknown candles file format")
+	ErrUnsupportedVersion = errors.New("unsupported candles file version")
+	ErrHeaderMismatch     = errors.New("candles file header mismatch")
+)
+
+// Describes the data of a candles file, it is stored at the start of the file
+type Header struct {
+	Version   uint8
+	Format    Format
+	Symbol    string
+	Interval  Interval
+	Exchange  string
+	CreatedAt int64 // milli seconds
+}
+
+func (h *Header) validate() error {
+	if !h.Format.valid() {
+		return errorWrap("invalid record format", ErrUnknownFormat)
+	}
+	if len(h.Symbol) > hdrEnd-hdrSymbol {
+		return errorWrap("symbol is too long", ErrUnknownFormat)
+	}
+	if len(h.Interval) > hdrExchange-hdrInterval {
+		return errorWrap("interval is too long", ErrUnknownFormat)
+	}
+	if len(h.Exchange) > hdrSymbol-hdrExchange {
+		return errorWrap("exchange is too long", ErrUnknownFormat)
+	}
+	return nil
+}
+
+// Check the file belongs to the dataset
+func (h *Header) match(exchange, symbol string, interval Interval) error {
+	if h.Symbol != symbol || h.Interval != interval || h.Exchange != exchange {
+		return errorWrap(h.Exchange+" "+h.Symbol+" "+string(h.Interval), ErrHeaderMismatch)
+	}
+	return nil
+}
+
+// Write the header to the start of a new data file, the version and
+// the creation time are set if they are empty
+func writeHeader(w io.Writer, h *Header) error {
+	h.Version = headerVersion
+	if h.CreatedAt == 0 {
+		h.CreatedAt = time.Now().UnixMilli()
+	}
+	if err := h.validate(); err != nil {
+		return err
+	}
+	var b [HeaderByteSize]byte
+	copy(b[hdrMagic:], headerMagic)
+	b[hdrVersion] = h.Version
+	b[hdrLayout] = byte(h.Format.Layout)
+	b[hdrEncoding] = byte(h.Format.Encoding)
+	binary.LittleEndian.PutUint64(b[hdrCreatedAt:hdrInterval], uint64(h.CreatedAt))
+	copy(b[hdrInterval:hdrExchange], h.Interval)
+	copy(b[hdrExchange:hdrSymbol], h.Exchange)
+	copy(b[hdrSymbol:hdrEnd], h.Symbol)
+	_, err := w.Write(b[:])
+	return err
+}
+
+// Read and check the header of an existing data file
+func readHeader(r io.ReaderAt) (*Header, error) {
+	var b [HeaderByteSize]byte
+	n, err := r.ReadAt(b[:], 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if n < hdrVersion+1 || !bytes.Equal(b[hdrMagic:hdrVersion], []byte(headerMagic)) {
+		return nil, ErrUnknownFormat
+	}
+	if b[hdrVersion] != headerVersion {
+		return nil, ErrUnsupportedVersion
+	}
+	if n != len(b) {
+		return nil, errorWrap("short header", ErrUnknownFormat)
+	}
+	h := &Header{
+		Version: b[hdrVersion],
+		Format: Format{
+			Layout:   Layout(b[hdrLayout]),
+			Encoding: Encoding(b[hdrEncoding]),
+		},
+		CreatedAt: int64(binary.LittleEndian.Uint64(b[hdrCreatedAt:hdrInterval])),
+		Interval:  Interval(trimZero(b[hdrInterval:hdrExchange])),
+		Exchange:  trimZero(b[hdrExchange:hdrSymbol]),
+		Symbol:    trimZero(b[hdrSymbol:hdrEnd]),
+	}
+	if err = h.validate(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func trimZero(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
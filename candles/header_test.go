@@ -0,0 +1,64 @@
+package candles
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+const testHdrFile = "/tmp/test-candles-hdr.bin"
+
+func TestHeaderMetadata(t *testing.T) {
+	want := Header{
+		Format:    Format{Layout: LayoutExtended, Encoding: EncodingFloat64},
+		Symbol:    "BTCUSDT",
+		Interval:  Interval1m,
+		Exchange:  DefaultExchange,
+		CreatedAt: 1708369200000,
+	}
+	stg, err := NewFileStorage(testHdrFile, want)
+	if err != nil {
+		t.Fatalf("create new storage: %s", err.Error())
+	}
+	if err = stg.Save([]Candle{a}); err != nil {
+		t.Errorf("save candles: %s", err.Error())
+	}
+	stg.Close()
+
+	stg, err = FileStorage(testHdrFile)
+	if err != nil {
+		t.Fatalf("open existing storage: %s", err.Error())
+	}
+	defer stg.Close()
+
+	want.Version = headerVersion
+	if got := stg.Header(); got != want {
+		t.Errorf("header: want %#v, got %#v", want, got)
+	}
+	n, err := stg.SizeCandles()
+	if err != nil || n != 1 {
+		t.Errorf("size candles: want 1, got %d (%v)", n, err)
+	}
+	cTime, err := stg.FirstCandleCloseTime()
+	if err != nil || cTime != SecToMilli(a.CTime) {
+		t.Errorf("first candle close time: want %d, got %d (%v)", SecToMilli(a.CTime), cTime, err)
+	}
+}
+
+func TestHeaderValidation(t *testing.T) {
+	_, err := NewFileStorage(testHdrFile, Header{Format: Format{Layout: 7}})
+	if !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("invalid layout: want error '%s', got '%v'", ErrUnknownFormat, err)
+	}
+
+	b := make([]byte, HeaderByteSize)
+	copy(b, headerMagic)
+	b[hdrVersion] = headerVersion + 1
+	if err = os.WriteFile(testHdrFile, b, DefaultFilePerm); err != nil {
+		t.Fatalf("write file: %s", err.Error())
+	}
+	_, err = FileStorage(testHdrFile)
+	if !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("future version: want error '%s', got '%v'", ErrUnsupportedVersion, err)
+	}
+}
@@ -0,0 +1,141 @@
+package candles
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+// environment variables with the API base URL used when there is no one in the options,
+// every API has its own, so a mirror of one does not get the requests of the others
+const (
+	EnvBaseURL      = "LOADER_API_URL"   // Binance spot
+	EnvUSDMBaseURL  = "LOADER_FAPI_URL"  // Binance USD-M futures
+	EnvCOINMBaseURL = "LOADER_DAPI_URL"  // Binance COIN-M futures
+	EnvBybitBaseURL = "LOADER_BYBIT_URL" // Bybit
+)
+
+// the variable of every default base URL
+var baseURLEnvs = map[string]string{
+	apiBaseURL:   EnvBaseURL,
+	fapiBaseURL:  EnvUSDMBaseURL,
+	dapiBaseURL:  EnvCOINMBaseURL,
+	bybitBaseURL: EnvBybitBaseURL,
+}
+
+var ErrInvalidBaseURL = errors.New("base URL must be like https://host[:port][/path]")
+
+func hostClient(host string, isTLS bool, tlsConfig *tls.Config) *fasthttp.HostClient {
+	// single HostClient will be enough, so no need to use fasthttp.Client
+	return &fasthttp.HostClient{
+		Addr: fasthttp.AddMissingPort(host, isTLS),
+		// increase DNS cache time to an hour instead of default minute
+		Dial: (&fasthttp.TCPDialer{
+			DNSCacheDuration: time.Hour,
+		}).Dial,
+		DisableHeaderNamesNormalizing: true,
+		DisablePathNormalizing:        true,
+		IsTLS:                         isTLS,
+		TLSConfig:                     tlsConfig,
+		MaxIdleConnDuration:           time.Second * 10,
+		NoDefaultUserAgentHeader:      true,
+	}
+}
+
+// Returns the base URL of the options, of the environment variable of the API
+// or the default one without the trailing slash
+func baseURL(opts *LoadOptions, defaultURL string) string {
+	base := opts.BaseURL
+	if env, ok := baseURLEnvs[defaultURL]; ok && base == "" {
+		base = os.Getenv(env)
+	}
+	if base == "" {
+		base = defaultURL
+	}
+	return strings.TrimSuffix(base, "/")
+}
+
+// Check the base URL is an http or https one without a query
+func CheckBaseURL(base string) error {
+	// a base without a scheme is parsed as a path
+	u, err := url.Parse(base)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") ||
+		u.RawQuery != "" || u.Fragment != "" {
+		return errorWrap(base, ErrInvalidBaseURL)
+	}
+	return nil
+}
+
+// Sends GET requests to one endpoint with retries and the rate limit,
+// it is not safe for concurrent use
+type httpClient struct {
+	retry   RetryPolicy
+	limiter *RateLimiter
+	intChan chan os.Signal
+	uri     *fasthttp.URI
+	req     *fasthttp.Request
+	resp    *fasthttp.Response
+	hc      *fasthttp.HostClient
+}
+
+// The endpoint path is added to the base URL, the scheme of which sets TLS on or off
+func newHTTPClient(defaultURL, path string, opts *LoadOptions, intChan chan os.Signal) (*httpClient, error) {
+	c := &httpClient{
+		retry:   opts.Retry,
+		limiter: opts.Limiter,
+		intChan: intChan,
+		uri:     &fasthttp.URI{},
+		req:     &fasthttp.Request{},
+		resp:    &fasthttp.Response{},
+	}
+	if c.limiter == nil {
+		c.limiter = NewRateLimiter(WeightLimit(opts.Exchange))
+	}
+	base := baseURL(opts, defaultURL)
+	if err := CheckBaseURL(base); err != nil {
+		return nil, err
+	}
+	if err := c.uri.Parse(nil, []byte(base+path)); err != nil {
+		return nil, errorWrap(base, err)
+	}
+	c.hc = hostClient(string(c.uri.Host()), string(c.uri.Scheme()) == "https", opts.TLSConfig)
+	return c, nil
+}
+
+func (c *httpClient) close() {
+	c.hc.CloseIdleConnections()
+}
+
+// Request the endpoint with the query string, transient failures are retried
+// according to the policy. The body is valid until the next call
+func (c *httpClient) get(query []byte, weight int) ([]byte, error) {
+	c.uri.SetQueryStringBytes(query)
+	// make an inner copy of parsed uri
+	c.req.SetURI(c.uri)
+	err := retry(c.retry, c.intChan, func() error {
+		if err := c.limiter.wait(weight, c.intChan); err != nil {
+			return err
+		}
+		if err := c.hc.DoTimeout(c.req, c.resp, requestTimeout); err != nil {
+			return err
+		}
+		c.limiter.update(&c.resp.Header)
+		if code := c.resp.StatusCode(); code != fasthttp.StatusOK {
+			// rate limit and server errors are retried
+			return parseAPIError(code, c.resp.Body())
+		}
+		return nil
+	})
+	if err == ErrInterrupted {
+		return nil, err
+	}
+	if err != nil {
+		return nil, errorWrap("api do request", err)
+	}
+	return c.resp.Body(), nil
+}
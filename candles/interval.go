@@ -0,0 +1,164 @@
+package candles
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Kline interval as the exchange names it
+type Interval string
+
+const (
+	Interval1s  Interval = "1s"
+	Interval1m  Interval = "1m"
+	Interval3m  Interval = "3m"
+	Interval5m  Interval = "5m"
+	Interval15m Interval = "15m"
+	Interval30m Interval = "30m"
+	Interval1h  Interval = "1h"
+	Interval2h  Interval = "2h"
+	Interval4h  Interval = "4h"
+	Interval6h  Interval = "6h"
+	Interval8h  Interval = "8h"
+	Interval12h Interval = "12h"
+	Interval1d  Interval = "1d"
+	Interval3d  Interval = "3d"
+	Interval1w  Interval = "1w"
+	Interval1M  Interval = "1M"
+
+	DefaultInterval = Interval1s
+)
+
+var ErrInvalidInterval = errors.New("invalid interval")
+
+// the units of a derived interval
+var derivedUnits = map[byte]time.Duration{
+	's': time.Second,
+	'm': time.Minute,
+	'h': time.Hour,
+	'd': 24 * time.Hour,
+	'w': 7 * 24 * time.Hour,
+}
+
+// weeks open on monday like the exchange ones, the unix time starts on thursday
+const weekOffset = 4 * 24 * time.Hour
+
+// fixed length of every interval except a month which depends on the calendar
+var intervalDurations = map[Interval]time.Duration{
+	Interval1s:  time.Second,
+	Interval1m:  time.Minute,
+	Interval3m:  3 * time.Minute,
+	Interval5m:  5 * time.Minute,
+	Interval15m: 15 * time.Minute,
+	Interval30m: 30 * time.Minute,
+	Interval1h:  time.Hour,
+	Interval2h:  2 * time.Hour,
+	Interval4h:  4 * time.Hour,
+	Interval6h:  6 * time.Hour,
+	Interval8h:  8 * time.Hour,
+	Interval12h: 12 * time.Hour,
+	Interval1d:  24 * time.Hour,
+	Interval3d:  3 * 24 * time.Hour,
+	Interval1w:  7 * 24 * time.Hour,
+	Interval1M:  0,
+}
+
+// Check that the string is one of the exchange intervals, it is case sensitive
+// because 1m is a minute and 1M is a month
+func ParseInterval(s string) (Interval, error) {
+	i := Interval(s)
+	if _, ok := intervalDurations[i]; !ok {
+		return "", errorWrap(s, ErrInvalidInterval)
+	}
+	return i, nil
+}
+
+// Check that the string is an exchange interval or a derived one made of
+// a number and a unit of s m h d w like 7s or 90m, the datasets of derived
+// intervals are made of the candles of shorter ones
+func ParseDerivedInterval(s string) (Interval, error) {
+	if i, err := ParseInterval(s); err == nil {
+		return i, nil
+	}
+	i := Interval(s)
+	if len(s) > hdrExchange-hdrInterval || i.Duration() == 0 {
+		return "", errorWrap(s, ErrInvalidInterval)
+	}
+	return i, nil
+}
+
+// Returns the length of the interval, 0 for a month and an invalid interval
+func (i Interval) Duration() time.Duration {
+	if d, ok := intervalDurations[i]; ok {
+		return d
+	}
+	if len(i) < 2 {
+		return 0
+	}
+	unit, ok := derivedUnits[i[len(i)-1]]
+	if !ok {
+		return 0
+	}
+	// no sign and no leading zeros, a name is the only one of its duration and unit
+	num := string(i[:len(i)-1])
+	if num[0] < '1' || num[0] > '9' {
+		return 0
+	}
+	n, err := strconv.ParseInt(num, 10, 32)
+	// the duration must fit in nano seconds
+	if err != nil || n > math.MaxInt64/int64(unit) {
+		return 0
+	}
+	return time.Duration(n) * unit
+}
+
+// Returns the open time as milli seconds of the candle following the one opened at t
+func (i Interval) Next(t int64) int64 {
+	if i == Interval1M {
+		return time.UnixMilli(t).UTC().AddDate(0, 1, 0).UnixMilli()
+	}
+	return t + i.Duration().Milliseconds()
+}
+
+// Returns the open time as milli seconds of the candle preceding the one opened at t
+func (i Interval) Prev(t int64) int64 {
+	if i == Interval1M {
+		return time.UnixMilli(t).UTC().AddDate(0, -1, 0).UnixMilli()
+	}
+	return t - i.Duration().Milliseconds()
+}
+
+// Returns the open time as milli seconds of the candle which has the time t,
+// the candles are counted from the unix time, the weeks from a monday and
+// the months from the first day
+func (i Interval) OpenTime(t int64) int64 {
+	if i == Interval1M {
+		dt := time.UnixMilli(t).UTC()
+		return time.Date(dt.Year(), dt.Month(), 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	}
+	d := i.Duration()
+	if d <= 0 {
+		return t
+	}
+	var off int64
+	if d%derivedUnits['w'] == 0 {
+		off = weekOffset.Milliseconds()
+	}
+	ms := d.Milliseconds()
+	r := (t - off) % ms
+	if r < 0 {
+		r += ms
+	}
+	return t - r
+}
+
+// Part of the file name, a month gets a distinct name because 1m and 1M
+// are the same file on case insensitive file systems
+func (i Interval) fileSuffix() string {
+	if i == Interval1M {
+		return "-1mo"
+	}
+	return "-" + string(i)
+}
@@ -0,0 +1,85 @@
+package candles
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseInterval(t *testing.T) {
+	for _, s := range []string{"1s", "1m", "15m", "4h", "1d", "1w", "1M"} {
+		i, err := ParseInterval(s)
+		if err != nil {
+			t.Errorf("parse interval %s: %s", s, err.Error())
+		}
+		if string(i) != s {
+			t.Errorf("parse interval want %s, got %s", s, i)
+		}
+	}
+
+	_, err := ParseInterval("2m")
+	if !errors.Is(err, ErrInvalidInterval) {
+		t.Errorf("parse interval want error '%s', got '%v'", ErrInvalidInterval, err)
+	}
+}
+
+func TestIntervalNext(t *testing.T) {
+	// 2024-02-19 00:00:00
+	var ts int64 = 1708300800000
+	if got := Interval1m.Next(ts); got != ts+60000 {
+		t.Errorf("next 1m want %d, got %d", ts+60000, got)
+	}
+	// 2024-03-19 00:00:00, february 2024 has 29 days
+	var want int64 = 1710806400000
+	if got := Interval1M.Next(ts); got != want {
+		t.Errorf("next 1M want %d, got %d", want, got)
+	}
+}
+
+func TestDefaultFileName(t *testing.T) {
+	if got := DefaultFileName("BTCUSDT", Interval1m); got != "BTCUSDT-1m.bin" {
+		t.Errorf("file name want BTCUSDT-1m.bin, got %s", got)
+	}
+	if got := DefaultFileName("BTCUSDT", Interval1M); got != "BTCUSDT-1mo.bin" {
+		t.Errorf("file name want BTCUSDT-1mo.bin, got %s", got)
+	}
+}
+
+func TestParseDerivedInterval(t *testing.T) {
+	for s, want := range map[string]time.Duration{"7s": 7 * time.Second, "90m": 90 * time.Minute, "1h": time.Hour, "2w": 14 * 24 * time.Hour} {
+		i, err := ParseDerivedInterval(s)
+		if err != nil {
+			t.Errorf("parse derived interval %s: %s", s, err.Error())
+			continue
+		}
+		if i.Duration() != want {
+			t.Errorf("duration of %s want %s, got %s", s, want, i.Duration())
+		}
+	}
+	for _, s := range []string{"", "m", "0m", "07s", "-1h", "1y", "2M", "2000000000w", "1000000000d", "99999999h"} {
+		if _, err := ParseDerivedInterval(s); !errors.Is(err, ErrInvalidInterval) {
+			t.Errorf("parse derived interval %q want error '%s', got '%v'", s, ErrInvalidInterval, err)
+		}
+	}
+}
+
+func TestIntervalOpenTime(t *testing.T) {
+	// 2024-02-21 13:37:05, a wednesday
+	var ts int64 = 1708522625000
+	cases := []struct {
+		interval Interval
+		want     int64
+	}{
+		{"7s", 1708522620000},
+		{Interval5m, 1708522500000},
+		{"90m", 1708522200000},
+		{Interval1d, 1708473600000},
+		{Interval1w, 1708300800000}, // monday 2024-02-19
+		{Interval1M, 1706745600000}, // 2024-02-01
+	}
+	for _, c := range cases {
+		if got := c.interval.OpenTime(ts); got != c.want {
+			t.Errorf("open time %s want %d, got %d", c.interval, c.want, got)
+		}
+	}
+}
@@ -1,12 +1,10 @@
 package candles
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"os"
-	"time"
-
-	"github.com/valyala/fasthttp"
 )
 
 // max limit load candles 1000
@@ -18,56 +16,67 @@ func errorWrap(msg string, err error) error {
 	return fmt.Errorf("%s: %w", msg, err)
 }
 
-func hostClient(host string) *fasthttp.HostClient {
-	isTLS := true
-	// single HostClient will be enough, so no need to use fasthttp.Client
-	return &fasthttp.HostClient{
-		Addr: fasthttp.AddMissingPort(host, isTLS),
-		// increase DNS cache time to an hour instead of default minute
-		Dial: (&fasthttp.TCPDialer{
-			DNSCacheDuration: time.Hour,
-		}).Dial,
-		DisableHeaderNamesNormalizing: true,
-		DisablePathNormalizing:        true,
-		IsTLS:                         isTLS,
-		MaxIdleConnDuration:           time.Second * 10,
-		NoDefaultUserAgentHeader:      true,
+// What and how to download
+type LoadOptions struct {
+	// one of Exchanges, empty is Binance
+	Exchange string
+	Symbol   string
+	Interval Interval
+	Retry    RetryPolicy
+	// milli seconds, only candles closed not later are loaded, 0 means until now
+	EndTime int64
+	// the scheme, the host and an optional path prefix of the exchange API
+	// like http://127.0.0.1:8080, empty is the variable of the API like EnvBaseURL
+	// or the exchange default
+	BaseURL string
+	// used for https, nil is the default config
+	TLSConfig *tls.Config
+	// may be shared by loaders which use the same IP, a loader gets its own if nil
+	Limiter *RateLimiter
+}
+
+// Options to load the symbol with the default interval and retries
+func DefaultLoadOptions(symbol string) *LoadOptions {
+	return &LoadOptions{
+		Exchange: DefaultExchange,
+		Symbol:   symbol,
+		Interval: DefaultInterval,
+		Retry:    DefaultRetryPolicy(),
 	}
 }
 
-func Load(t int64, stg *Storage, intChan chan os.Signal, symbol string) error {
-	q := Query{}
-	q.Init(symbol)
-	uri := &fasthttp.URI{}
-	uri.Parse(nil, []byte(apiUriBase))
-	req := &fasthttp.Request{}
-	resp := &fasthttp.Response{}
-	hc := hostClient(string(uri.Host()))
-	defer hc.CloseIdleConnections()
+func Load(t int64, stg *Storage, intChan chan os.Signal, opts *LoadOptions) error {
+	src, err := NewSource(opts, intChan)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
 
-	var cs Candles
+	r := &BatchRequest{Symbol: opts.Symbol, Interval: opts.Interval, End: opts.EndTime}
+	cs := make([]Candle, src.BatchSize())
 	for {
-		uri.SetQueryStringBytes(q.QueryStringBytes(t))
-		// make an inner copy of parsed uri
-		req.SetURI(uri)
-		if err := hc.Do(req, resp); err != nil {
-			return errorWrap("api do request", err)
+		if opts.EndTime > 0 && t >= opts.EndTime {
+			return nil
 		}
-		n, err := parseCandles(resp.Body(), &cs)
+		r.Start = t
+		n, err := src.Fetch(r, cs)
 		if err != nil {
-			return errorWrap("parse candles", err)
+			return err
 		}
+		// a candle still open at the end is out of the range
+		full := len(cs) == n
+		n = cutAfter(cs[:n], opts.EndTime)
 		if err = stg.Save(cs[:n]); err != nil {
 			return errorWrap("save candles", err)
 		}
 
-		// conver the time of the last candle seconds to milli
-		t = SecToMilli(cs[n-1].CTime)
-
-		if len(cs) > n {
+		if !full || n < len(cs) {
 			// all done
 			return nil
 		}
+		// conver the time of the last candle seconds to milli
+		t = SecToMilli(cs[n-1].CTime)
+
 		select {
 		case <-intChan:
 			return ErrInterrupted
@@ -76,3 +85,17 @@ func Load(t int64, stg *Storage, intChan chan os.Signal, symbol string) error {
 		}
 	}
 }
+
+// Returns the number of candles closed not later than end (milli seconds),
+// the candles are sorted and 0 end means no limit
+func cutAfter(cs []Candle, end int64) int {
+	if end <= 0 {
+		return len(cs)
+	}
+	for i := len(cs); i > 0; i-- {
+		if SecToMilli(cs[i-1].CTime) <= end {
+			return i
+		}
+	}
+	return 0
+}
@@ -0,0 +1,19 @@
+package candles
+
+import "testing"
+
+func TestCutAfter(t *testing.T) {
+	cs := []Candle{testCandle(61), testCandle(62), testCandle(63)}
+	if n := cutAfter(cs, 0); n != 3 {
+		t.Errorf("no end: want 3, got %d", n)
+	}
+	if n := cutAfter(cs, 62000); n != 2 {
+		t.Errorf("end at the second close: want 2, got %d", n)
+	}
+	if n := cutAfter(cs, 62999); n != 2 {
+		t.Errorf("end inside the third candle: want 2, got %d", n)
+	}
+	if n := cutAfter(cs, 60000); n != 0 {
+		t.Errorf("end before the first close: want 0, got %d", n)
+	}
+}
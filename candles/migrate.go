@@ -0,0 +1,240 @@
+package candles
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// headerless record of the first format: high, low, close, volume, close time
+	LegacyCandleByteSize = 5 * 4
+	// candles converted at once
+	migrateBatch = 4096
+)
+
+var (
+	ErrAlreadyMigrated = errors.New("file already has a header")
+	ErrVerifyMigration = errors.New("migrated file does not match the source")
+)
+
+// Reads candles from a headerless file of the first format
+type legacyReader struct {
+	fd       *os.File
+	interval Interval
+	pos      int64
+	buf      []byte
+}
+
+func openLegacy(path string, interval Interval) (*legacyReader, error) {
+	fd, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = readHeader(fd); err == nil {
+		fd.Close()
+		return nil, errorWrap(path, ErrAlreadyMigrated)
+	}
+	fi, err := fd.Stat()
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+	if fi.Size()%LegacyCandleByteSize != 0 {
+		fd.Close()
+		return nil, errors.New("open a corrupted legacy candles file")
+	}
+	return &legacyReader{fd: fd, interval: interval}, nil
+}
+
+func (r *legacyReader) Close() error {
+	return r.fd.Close()
+}
+
+// Read the next candles, returns io.EOF after the last one
+func (r *legacyReader) read(cs []Candle) (int, error) {
+	nb := len(cs) * LegacyCandleByteSize
+	if nb > cap(r.buf) {
+		r.buf = make([]byte, nb)
+	}
+	bs := r.buf[:nb]
+	n, err := r.fd.ReadAt(bs, r.pos)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	r.pos += int64(n)
+	n = n / LegacyCandleByteSize
+	legacyBs2cs(bs, cs, n)
+	// the legacy format has no open price, it stays 0 which no real price is
+	for i := 0; i < n; i++ {
+		cs[i].OTime = uint32(r.interval.Prev(SecToMilli(cs[i].CTime)) / 1000)
+	}
+	if n == 0 && err == nil {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// The decoding of the first format
+func legacyBs2cs(bs []byte, cs []Candle, n int) {
+	var off int
+	for i := 0; i < n; i++ {
+		off = i * LegacyCandleByteSize
+		cs[i] = Candle{}
+		cs[i].HPrice = float64(math.Float32frombits(binary.LittleEndian.Uint32(bs[off : 4+off])))
+		cs[i].LPrice = float64(math.Float32frombits(binary.LittleEndian.Uint32(bs[4+off : 8+off])))
+		cs[i].CPrice = float64(math.Float32frombits(binary.LittleEndian.Uint32(bs[8+off : 12+off])))
+		cs[i].Volume = float64(math.Float32frombits(binary.LittleEndian.Uint32(bs[12+off : 16+off])))
+		cs[i].CTime = binary.LittleEndian.Uint32(bs[16+off : 20+off])
+	}
+}
+
+// Guess the interval of a legacy file from the distance between its first candles
+func InferLegacyInterval(path string) (Interval, error) {
+	r, err := openLegacy(path, DefaultInterval)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	var cs [2]Candle
+	n, err := r.read(cs[:])
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	if n < 2 {
+		return "", errors.New("not enough candles to infer the interval")
+	}
+	t := SecToMilli(cs[0].CTime)
+	for i := range intervalDurations {
+		if i.Next(t) == SecToMilli(cs[1].CTime) {
+			return i, nil
+		}
+	}
+	return "", errors.New("the first candles are not a known interval apart")
+}
+
+// Convert a headerless file of the first format at src to a new data file at dst.
+// The result is written to a temporary file, checked against the source and only
+// then renamed to dst, so dst is either complete or untouched.
+// Returns the number of converted candles
+func Migrate(src, dst string, h Header) (int64, error) {
+	r, err := openLegacy(src, h.Interval)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".tmp-*")
+	if err != nil {
+		return 0, err
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpPath) // there is nothing to remove after the rename
+
+	total, err := migrateTo(r, tmpPath, h)
+	if err != nil {
+		return 0, err
+	}
+	if err = verifyMigration(src, tmpPath, h.Interval, total); err != nil {
+		return 0, err
+	}
+	if err = os.Chmod(tmpPath, DefaultFilePerm); err != nil {
+		return 0, err
+	}
+	if err = os.Rename(tmpPath, dst); err != nil {
+		return 0, err
+	}
+	return total, syncDir(filepath.Dir(dst))
+}
+
+func migrateTo(r *legacyReader, path string, h Header) (int64, error) {
+	stg, err := NewFileStorage(path, h)
+	if err != nil {
+		return 0, err
+	}
+	defer stg.Close()
+
+	var total int64
+	cs := make([]Candle, migrateBatch)
+	for {
+		n, err := r.read(cs)
+		if err != nil && err != io.EOF {
+			return 0, errorWrap("read legacy candles", err)
+		}
+		if err = stg.Save(cs[:n]); err != nil {
+			return 0, errorWrap("save candles", err)
+		}
+		total += int64(n)
+		if n < len(cs) {
+			break
+		}
+	}
+	return total, stg.Sync()
+}
+
+// Compare the number of candles and every close time and close price
+func verifyMigration(src, dst string, interval Interval, total int64) error {
+	r, err := openLegacy(src, interval)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	stg, err := FileStorage(dst)
+	if err != nil {
+		return err
+	}
+	defer stg.Close()
+
+	size, err := stg.SizeCandles()
+	if err != nil {
+		return err
+	}
+	if size != total {
+		return errorWrap("number of candles", ErrVerifyMigration)
+	}
+
+	want := make([]Candle, migrateBatch)
+	got := make([]Candle, migrateBatch)
+	var checked int64
+	for {
+		n1, err := r.read(want)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		n2, err := stg.Read(got)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if n1 != n2 {
+			return errorWrap("number of candles", ErrVerifyMigration)
+		}
+		for i := 0; i < n1; i++ {
+			if want[i].CTime != got[i].CTime || want[i].CPrice != got[i].CPrice {
+				return errorWrap("candle close time or price", ErrVerifyMigration)
+			}
+		}
+		checked += int64(n1)
+		if n1 < len(want) {
+			break
+		}
+	}
+	if checked != total {
+		return errorWrap("number of candles", ErrVerifyMigration)
+	}
+	return nil
+}
+
+// Persist the rename in the directory entry
+func syncDir(dir string) error {
+	fd, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+	return fd.Sync()
+}
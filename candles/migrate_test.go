@@ -0,0 +1,81 @@
+package candles
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"testing"
+)
+
+const (
+	testLegacyFile   = "/tmp/test-legacy.bin"
+	testMigratedFile = "/tmp/test-legacy-1m.bin"
+)
+
+func writeLegacy(t *testing.T, cs []Candle) {
+	bs := make([]byte, len(cs)*LegacyCandleByteSize)
+	for i, c := range cs {
+		off := i * LegacyCandleByteSize
+		binary.LittleEndian.PutUint32(bs[off:], math.Float32bits(float32(c.HPrice)))
+		binary.LittleEndian.PutUint32(bs[off+4:], math.Float32bits(float32(c.LPrice)))
+		binary.LittleEndian.PutUint32(bs[off+8:], math.Float32bits(float32(c.CPrice)))
+		binary.LittleEndian.PutUint32(bs[off+12:], math.Float32bits(float32(c.Volume)))
+		binary.LittleEndian.PutUint32(bs[off+16:], c.CTime)
+	}
+	if err := os.WriteFile(testLegacyFile, bs, DefaultFilePerm); err != nil {
+		t.Fatalf("write legacy file: %s", err.Error())
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	os.Remove(testMigratedFile)
+	legacy := []Candle{
+		{HPrice: 2, LPrice: 1, CPrice: 1.5, Volume: 10, CTime: 1708369260},
+		{HPrice: 3, LPrice: 1, CPrice: 2.5, Volume: 20, CTime: 1708369320},
+		{HPrice: 4, LPrice: 2, CPrice: 3.5, Volume: 30, CTime: 1708369380},
+	}
+	writeLegacy(t, legacy)
+
+	interval, err := InferLegacyInterval(testLegacyFile)
+	if err != nil {
+		t.Fatalf("infer interval: %s", err.Error())
+	}
+	if interval != Interval1m {
+		t.Errorf("infer interval: want %s, got %s", Interval1m, interval)
+	}
+
+	h := Header{Symbol: "ETHUSDT", Interval: interval, Exchange: DefaultExchange}
+	n, err := Migrate(testLegacyFile, testMigratedFile, h)
+	if err != nil {
+		t.Fatalf("migrate: %s", err.Error())
+	}
+	if n != int64(len(legacy)) {
+		t.Errorf("migrated candles: want %d, got %d", len(legacy), n)
+	}
+
+	stg, err := FileStorage(testMigratedFile)
+	if err != nil {
+		t.Fatalf("open migrated storage: %s", err.Error())
+	}
+	defer stg.Close()
+	if got := stg.Header(); got.Symbol != h.Symbol || got.Interval != h.Interval {
+		t.Errorf("migrated header: want %#v, got %#v", h, got)
+	}
+	cs, err := stg.ReadAll()
+	if err != nil {
+		t.Fatalf("read migrated candles: %s", err.Error())
+	}
+	want := legacy[1]
+	// the legacy format has no open
+	want.OPrice = 0
+	want.OTime = legacy[0].CTime
+	if len(cs) != len(legacy) || cs[1] != want {
+		t.Errorf("migrated candle: want %#v, got %#v", want, cs)
+	}
+
+	_, err = Migrate(testMigratedFile, testMigratedFile+".2", h)
+	if !errors.Is(err, ErrAlreadyMigrated) {
+		t.Errorf("migrate twice: want error '%s', got '%v'", ErrAlreadyMigrated, err)
+	}
+}
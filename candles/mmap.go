@@ -0,0 +1,170 @@
+package candles
+
+import (
+	"encoding/binary"
+	"os"
+	"sort"
+)
+
+// A read-only memory mapping of a data file. The candles are decoded from the
+// mapped bytes only when their fields are read, so goroutines may share one
+// mapping without copies of the data. It is safe for concurrent use until Close
+type MappedStorage struct {
+	fd     *os.File
+	data   []byte // the whole file
+	recs   []byte // the candles after the header
+	header *Header
+	size   int
+	n      int
+}
+
+// Map the data file of the dataset at the default path
+func DefaultMappedStorage(exchange, symbol string, interval Interval) (*MappedStorage, error) {
+	path, err := DefaultPath(exchange, symbol, interval)
+	if err != nil {
+		return nil, err
+	}
+	m, err := MappedFileStorage(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = m.header.match(exchange, symbol, interval); err != nil {
+		m.Close()
+		return nil, err
+	}
+	return m, nil
+}
+
+// Map an existing data file, the candles written later are not seen
+// and neither is a partial candle at the end
+func MappedFileStorage(path string) (*MappedStorage, error) {
+	fd, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	h, err := readHeader(fd)
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+	fi, err := fd.Stat()
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+	m := &MappedStorage{fd: fd, header: h, size: h.Format.RecordSize()}
+	// a partial candle at the end is being written or is torn
+	size := fi.Size() - HeaderByteSize
+	size -= size % int64(m.size)
+	if m.data, err = mapFile(fd, int(fi.Size())); err != nil {
+		fd.Close()
+		return nil, errorWrap("map candles file", err)
+	}
+	m.recs = m.data[HeaderByteSize:]
+	m.n = int(size / int64(m.size))
+	return m, nil
+}
+
+// Unmap the file, the views taken from it must not be used after
+func (m *MappedStorage) Close() error {
+	err := unmapFile(m.data)
+	m.data, m.recs, m.n = nil, nil, 0
+	if cerr := m.fd.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Returns a copy of the data file header
+func (m *MappedStorage) Header() Header {
+	return *m.header
+}
+
+// Returns the format of the candles in the data file
+func (m *MappedStorage) Format() Format {
+	return m.header.Format
+}
+
+// Returns the number of candles
+func (m *MappedStorage) Len() int {
+	return m.n
+}
+
+// Returns the view of the candle i, it panics when i is out of range
+func (m *MappedStorage) At(i int) CandleView {
+	if i < 0 || i >= m.n {
+		panic("candles: index out of range")
+	}
+	off := i * m.size
+	return CandleView{b: m.recs[off : off+m.size : off+m.size], f: m.header.Format}
+}
+
+// Decode the candle i
+func (m *MappedStorage) Candle(i int) Candle {
+	return m.At(i).Candle()
+}
+
+// Returns the number of candles closed not later than t (milli seconds),
+// the candles are expected to be sorted
+func (m *MappedStorage) SearchTime(t int64) int {
+	return sort.Search(m.n, func(i int) bool {
+		return SecToMilli(m.At(i).CTime()) > t
+	})
+}
+
+// Returns the indexes [i, j) of the candles closed later than from
+// and not later than to (milli seconds)
+func (m *MappedStorage) Range(from, to int64) (int, int) {
+	i := m.SearchTime(from)
+	j := m.SearchTime(to)
+	return i, max(i, j)
+}
+
+// The record of a candle in a mapped file, the fields are decoded when read.
+// The fields of the extended layout are zero for the compact one
+type CandleView struct {
+	b []byte
+	f Format
+}
+
+func (v CandleView) OTime() uint32 {
+	return binary.LittleEndian.Uint32(v.b[:4])
+}
+
+func (v CandleView) CTime() uint32 {
+	return binary.LittleEndian.Uint32(v.b[4:8])
+}
+
+// Returns the float with k floats and skip bytes before it after the times
+func (v CandleView) float(k, skip int) float64 {
+	off := 8 + skip + k*v.f.floatSize()
+	if off >= len(v.b) {
+		return 0
+	}
+	f, _ := v.f.float(v.b[off:])
+	return f
+}
+
+func (v CandleView) OPrice() float64   { return v.float(0, 0) }
+func (v CandleView) HPrice() float64   { return v.float(1, 0) }
+func (v CandleView) LPrice() float64   { return v.float(2, 0) }
+func (v CandleView) CPrice() float64   { return v.float(3, 0) }
+func (v CandleView) Volume() float64   { return v.float(4, 0) }
+func (v CandleView) QVolume() float64  { return v.float(5, 0) }
+func (v CandleView) TBVolume() float64 { return v.float(6, 4) }
+func (v CandleView) TQVolume() float64 { return v.float(7, 4) }
+
+func (v CandleView) Trades() uint32 {
+	off := 8 + 6*v.f.floatSize()
+	if off >= len(v.b) {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(v.b[off:])
+}
+
+// Decode all the fields
+func (v CandleView) Candle() Candle {
+	var c Candle
+	v.f.decode(v.b, &c)
+	return c
+}
@@ -0,0 +1,21 @@
+//go:build !unix
+
+package candles
+
+import (
+	"io"
+	"os"
+)
+
+// Without mmap the file is read into memory once, the views still share it
+func mapFile(fd *os.File, size int) ([]byte, error) {
+	b := make([]byte, size)
+	if _, err := fd.ReadAt(b, 0); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return b, nil
+}
+
+func unmapFile(b []byte) error {
+	return nil
+}
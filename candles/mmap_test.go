@@ -0,0 +1,84 @@
+package candles
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestMappedStorage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "BTCUSDT-1m.bin")
+	h := Header{Symbol: "BTCUSDT", Interval: Interval1m, Format: Format{Layout: LayoutExtended}}
+	stg, err := NewFileStorage(path, h)
+	if err != nil {
+		t.Fatalf("create new storage: %s", err.Error())
+	}
+	cs := make([]Candle, 100)
+	for i := range cs {
+		f := float64(i)
+		ot := uint32(1708300800 + i*60)
+		cs[i] = Candle{f + 0.5, f + 1, f, f + 0.25, 10 * f, ot, ot + 60, 20 * f, uint32(i), 5 * f, 7 * f}
+	}
+	if err = stg.Save(cs); err != nil {
+		t.Fatalf("save candles: %s", err.Error())
+	}
+	stg.Close()
+
+	m, err := MappedFileStorage(path)
+	if err != nil {
+		t.Fatalf("map storage: %s", err.Error())
+	}
+	defer m.Close()
+	if m.Len() != len(cs) {
+		t.Fatalf("mapped candles: want %d, got %d", len(cs), m.Len())
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range cs {
+				if got := m.Candle(i); got != cs[i] {
+					t.Errorf("candle %d: want %v, got %v", i, cs[i], got)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	v := m.At(42)
+	if v.CTime() != cs[42].CTime || v.HPrice() != cs[42].HPrice || v.Trades() != 42 ||
+		v.QVolume() != cs[42].QVolume || v.TBVolume() != cs[42].TBVolume || v.TQVolume() != cs[42].TQVolume {
+		t.Errorf("view 42: want %v, got %v", cs[42], v.Candle())
+	}
+
+	// the candles 10..19 close after the 10th and not after the 20th
+	i, j := m.Range(SecToMilli(cs[9].CTime), SecToMilli(cs[19].CTime))
+	if i != 10 || j != 20 {
+		t.Errorf("range: want 10 20, got %d %d", i, j)
+	}
+	if i = m.SearchTime(SecToMilli(cs[99].CTime)); i != 100 {
+		t.Errorf("search after the last: want 100, got %d", i)
+	}
+}
+
+func TestMappedStorageEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "BTCUSDT-1s.bin")
+	stg, err := NewFileStorage(path, Header{})
+	if err != nil {
+		t.Fatalf("create new storage: %s", err.Error())
+	}
+	stg.Close()
+
+	m, err := MappedFileStorage(path)
+	if err != nil {
+		t.Fatalf("map empty storage: %s", err.Error())
+	}
+	if m.Len() != 0 || m.SearchTime(1) != 0 {
+		t.Errorf("empty storage: want 0 candles, got %d", m.Len())
+	}
+	if err = m.Close(); err != nil {
+		t.Errorf("close: %s", err.Error())
+	}
+}
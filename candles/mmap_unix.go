@@ -0,0 +1,22 @@
+//go:build unix
+
+package candles
+
+import (
+	"os"
+	"syscall"
+)
+
+func mapFile(fd *os.File, size int) ([]byte, error) {
+	if size == 0 {
+		return nil, nil
+	}
+	return syscall.Mmap(int(fd.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+}
+
+func unmapFile(b []byte) error {
+	if b == nil {
+		return nil
+	}
+	return syscall.Munmap(b)
+}
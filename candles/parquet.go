@@ -0,0 +1,365 @@
+package candles
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+const (
+	// rows of a row group, about 12 days of 1s candles
+	DefaultParquetRowGroup = 1 << 20
+	// values of a data page
+	parquetPageRows = 1 << 16
+	parquetMagic    = "PAR1"
+	parquetVersion  = 1
+	parquetCreator  = "loader"
+)
+
+// physical types, the repetition, the encodings and the page type of the format
+const (
+	pqInt64  = 2
+	pqFloat  = 4
+	pqDouble = 5
+
+	pqRequired  = 0
+	pqPlain     = 0
+	pqRLE       = 3
+	pqDataPage  = 0
+	pqTimestamp = 9 // the converted type TIMESTAMP_MILLIS
+)
+
+// A column of a row group written to the file
+type parquetChunk struct {
+	offset int64
+	size   int64
+}
+
+// Writes the columns of candles with plain encoding and no compression,
+// the times are timestamps in milli seconds, the prices and the volumes are
+// float or double as they are stored. Row groups are kept in memory column
+// by column and written when they are full
+type parquetWriter struct {
+	w        *bufio.Writer
+	offset   int64
+	h        Header
+	e        *rowEncoder
+	rowGroup int
+	// plain encoded values of the current row group
+	cols   [][]byte
+	rows   int
+	groups [][]parquetChunk
+	sizes  []int64 // rows of the groups
+	t      thriftWriter
+}
+
+func NewParquetWriter(w io.Writer, h Header, opts *ExportOptions) (CandleWriter, error) {
+	e, err := newRowEncoder(h, opts)
+	if err != nil {
+		return nil, err
+	}
+	p := &parquetWriter{
+		w:        bufio.NewWriter(w),
+		h:        h,
+		e:        e,
+		rowGroup: opts.RowGroupRows,
+		cols:     make([][]byte, len(e.fields)),
+	}
+	if p.rowGroup <= 0 {
+		p.rowGroup = DefaultParquetRowGroup
+	}
+	if err = p.write([]byte(parquetMagic)); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *parquetWriter) write(b []byte) error {
+	n, err := p.w.Write(b)
+	p.offset += int64(n)
+	return err
+}
+
+// Returns the physical type of the field
+func (p *parquetWriter) fieldType(f int) int32 {
+	switch {
+	case f == csvOpenTime || f == csvCloseTime || f == csvTrades:
+		return pqInt64
+	case p.e.bitSize == 32:
+		return pqFloat
+	}
+	return pqDouble
+}
+
+func (p *parquetWriter) WriteCandles(cs []Candle) error {
+	for i := range cs {
+		c := &cs[i]
+		for j, f := range p.e.fields {
+			b := p.cols[j]
+			switch f {
+			case csvOpenTime:
+				b = binary.LittleEndian.AppendUint64(b, uint64(SecToMilli(c.OTime)))
+			case csvCloseTime:
+				b = binary.LittleEndian.AppendUint64(b, uint64(SecToMilli(c.CTime)))
+			case csvTrades:
+				b = binary.LittleEndian.AppendUint64(b, uint64(c.Trades))
+			default:
+				v := candleFloat(c, f)
+				if p.e.bitSize == 32 {
+					b = binary.LittleEndian.AppendUint32(b, math.Float32bits(float32(v)))
+				} else {
+					b = binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+				}
+			}
+			p.cols[j] = b
+		}
+		p.rows++
+		if p.rows == p.rowGroup {
+			if err := p.flushGroup(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Write the columns of the current row group in pages
+func (p *parquetWriter) flushGroup() error {
+	chunks := make([]parquetChunk, len(p.cols))
+	for j, col := range p.cols {
+		chunks[j].offset = p.offset
+		valueSize := len(col) / p.rows
+		for start := 0; start < p.rows; start += parquetPageRows {
+			n := min(parquetPageRows, p.rows-start)
+			data := col[start*valueSize : (start+n)*valueSize]
+			p.t.reset()
+			p.t.i32(1, pqDataPage)
+			p.t.i32(2, int32(len(data)))
+			p.t.i32(3, int32(len(data)))
+			p.t.structBegin(5)
+			p.t.i32(1, int32(n))
+			p.t.i32(2, pqPlain)
+			p.t.i32(3, pqRLE)
+			p.t.i32(4, pqRLE)
+			p.t.structEnd()
+			p.t.stop()
+			if err := p.write(p.t.b); err != nil {
+				return err
+			}
+			if err := p.write(data); err != nil {
+				return err
+			}
+		}
+		chunks[j].size = p.offset - chunks[j].offset
+		p.cols[j] = col[:0]
+	}
+	p.groups = append(p.groups, chunks)
+	p.sizes = append(p.sizes, int64(p.rows))
+	p.rows = 0
+	return nil
+}
+
+func (p *parquetWriter) Close() error {
+	if p.rows > 0 {
+		if err := p.flushGroup(); err != nil {
+			return err
+		}
+	}
+	p.writeFileMetaData()
+	if err := p.write(p.t.b); err != nil {
+		return err
+	}
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], uint32(len(p.t.b)))
+	if err := p.write(b[:]); err != nil {
+		return err
+	}
+	if err := p.write([]byte(parquetMagic)); err != nil {
+		return err
+	}
+	return p.w.Flush()
+}
+
+// The footer with the schema, the row groups and the dataset of the header
+func (p *parquetWriter) writeFileMetaData() {
+	t := &p.t
+	t.reset()
+	t.i32(1, parquetVersion)
+
+	t.listBegin(2, thriftStruct, len(p.e.fields)+1)
+	t.elemBegin()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(p.e.fields)))
+	t.structEnd()
+	for j, f := range p.e.fields {
+		t.elemBegin()
+		t.i32(1, p.fieldType(f))
+		t.i32(3, pqRequired)
+		t.binary(4, p.e.names[j])
+		if f == csvOpenTime || f == csvCloseTime {
+			t.i32(6, pqTimestamp)
+			// LogicalType.TIMESTAMP{isAdjustedToUTC: true, unit: MILLIS}
+			t.structBegin(10)
+			t.structBegin(8)
+			t.bool(1, true)
+			t.structBegin(2)
+			t.structBegin(1)
+			t.structEnd()
+			t.structEnd()
+			t.structEnd()
+			t.structEnd()
+		}
+		t.structEnd()
+	}
+
+	var rows int64
+	for _, n := range p.sizes {
+		rows += n
+	}
+	t.i64(3, rows)
+
+	t.listBegin(4, thriftStruct, len(p.groups))
+	for g, chunks := range p.groups {
+		t.elemBegin()
+		t.listBegin(1, thriftStruct, len(chunks))
+		var total int64
+		for j, c := range chunks {
+			total += c.size
+			t.elemBegin()
+			t.i64(2, c.offset)
+			t.structBegin(3)
+			t.i32(1, p.fieldType(p.e.fields[j]))
+			t.listBegin(2, thriftI32, 2)
+			t.elemI32(pqPlain)
+			t.elemI32(pqRLE)
+			t.listBegin(3, thriftBinary, 1)
+			t.elemBinary(p.e.names[j])
+			t.i32(4, 0) // uncompressed
+			t.i64(5, p.sizes[g])
+			t.i64(6, c.size)
+			t.i64(7, c.size)
+			t.i64(9, c.offset)
+			t.structEnd()
+			t.structEnd()
+		}
+		t.i64(2, total)
+		t.i64(3, p.sizes[g])
+		t.structEnd()
+	}
+
+	kv := [...][2]string{
+		{"symbol", p.h.Symbol},
+		{"interval", string(p.h.Interval)},
+		{"exchange", p.h.Exchange},
+	}
+	t.listBegin(5, thriftStruct, len(kv))
+	for _, v := range kv {
+		t.elemBegin()
+		t.binary(1, v[0])
+		t.binary(2, v[1])
+		t.structEnd()
+	}
+	t.binary(6, parquetCreator)
+	t.stop()
+}
+
+// the types of the thrift compact protocol
+const (
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// Encodes the parquet metadata with the thrift compact protocol
+type thriftWriter struct {
+	b []byte
+	// the id of the previous field of the current struct and of the outer ones
+	last  int16
+	stack []int16
+}
+
+func (t *thriftWriter) reset() {
+	t.b = t.b[:0]
+	t.last = 0
+	t.stack = t.stack[:0]
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	if d := id - t.last; d > 0 && d <= 15 {
+		t.b = append(t.b, byte(d)<<4|typ)
+	} else {
+		t.b = append(t.b, typ)
+		t.b = binary.AppendVarint(t.b, int64(id))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.b = binary.AppendVarint(t.b, int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.b = binary.AppendVarint(t.b, v)
+}
+
+func (t *thriftWriter) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.elemBinary(s)
+}
+
+// the value of a bool is in the type of the field
+func (t *thriftWriter) bool(id int16, v bool) {
+	if v {
+		t.field(id, thriftTrue)
+	} else {
+		t.field(id, thriftFalse)
+	}
+}
+
+func (t *thriftWriter) structBegin(id int16) {
+	t.field(id, thriftStruct)
+	t.elemBegin()
+}
+
+// A struct in a list has no field header
+func (t *thriftWriter) elemBegin() {
+	t.stack = append(t.stack, t.last)
+	t.last = 0
+}
+
+func (t *thriftWriter) structEnd() {
+	t.stop()
+	t.last = t.stack[len(t.stack)-1]
+	t.stack = t.stack[:len(t.stack)-1]
+}
+
+// The end of the fields of a struct
+func (t *thriftWriter) stop() {
+	t.b = append(t.b, 0)
+}
+
+func (t *thriftWriter) listBegin(id int16, elemType byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.b = append(t.b, byte(n)<<4|elemType)
+		return
+	}
+	t.b = append(t.b, 0xf0|elemType)
+	t.b = binary.AppendUvarint(t.b, uint64(n))
+}
+
+func (t *thriftWriter) elemI32(v int32) {
+	t.b = binary.AppendVarint(t.b, int64(v))
+}
+
+func (t *thriftWriter) elemBinary(s string) {
+	t.b = binary.AppendUvarint(t.b, uint64(len(s)))
+	t.b = append(t.b, s...)
+}
@@ -0,0 +1,263 @@
+package candles
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestThriftWriter(t *testing.T) {
+	var tw thriftWriter
+	tw.i32(1, 1)
+	tw.structBegin(3)
+	tw.bool(1, true)
+	tw.structEnd()
+	// a field id after a long jump is written apart from the type
+	tw.binary(20, "ab")
+	tw.stop()
+	want := []byte{0x15, 0x02, 0x2c, 0x11, 0x00, 0x08, 0x28, 0x02, 'a', 'b', 0x00}
+	if !bytes.Equal(tw.b, want) {
+		t.Errorf("thrift: want %x, got %x", want, tw.b)
+	}
+}
+
+func TestParquetWriter(t *testing.T) {
+	h := Header{
+		Format:   Format{Layout: LayoutCompact, Encoding: EncodingFloat64},
+		Symbol:   "BTCUSDT",
+		Interval: Interval1s,
+		Exchange: ExchangeBinance,
+	}
+	var b bytes.Buffer
+	w, err := NewParquetWriter(&b, h, &ExportOptions{Columns: []string{"open_time", "close"}, RowGroupRows: 2})
+	if err != nil {
+		t.Fatalf("new parquet writer: %s", err.Error())
+	}
+	cs := []Candle{testCandle(1708300801), testCandle(1708300802), testCandle(1708300803)}
+	cs[0].CPrice = 1.5
+	if err = w.WriteCandles(cs); err != nil {
+		t.Fatalf("write candles: %s", err.Error())
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("close: %s", err.Error())
+	}
+
+	f := b.Bytes()
+	if string(f[:4]) != parquetMagic || string(f[len(f)-4:]) != parquetMagic {
+		t.Fatalf("magic: got %q and %q", f[:4], f[len(f)-4:])
+	}
+	footerSize := int(binary.LittleEndian.Uint32(f[len(f)-8:]))
+	footerStart := int64(len(f) - 8 - footerSize)
+	r := &thriftReader{b: f[footerStart : len(f)-8]}
+	meta := r.structure()
+	if r.err != nil || len(r.b) != 0 {
+		t.Fatalf("file metadata: decoded with %d bytes left, %v", len(r.b), r.err)
+	}
+	if meta[1] != int64(parquetVersion) || meta[3] != int64(len(cs)) || string(meta[6].([]byte)) != parquetCreator {
+		t.Errorf("file metadata: want version 1, 3 rows by loader, got %v %v %q", meta[1], meta[3], meta[6])
+	}
+
+	// SchemaElement type, repetition, name, children and converted type,
+	// LogicalType TIMESTAMP{isAdjustedToUTC: true, unit: MILLIS}
+	schema := meta[2].([]any)
+	if len(schema) != 3 {
+		t.Fatalf("schema: want the root and 2 columns, got %d", len(schema))
+	}
+	root := schema[0].(thriftFields)
+	if string(root[4].([]byte)) != "schema" || root[5] != int64(2) {
+		t.Errorf("schema root: want schema of 2 columns, got %v", root)
+	}
+	timeType := thriftFields{8: thriftFields{1: true, 2: thriftFields{1: thriftFields{}}}}
+	wantSchema := []thriftFields{
+		{1: int64(pqInt64), 3: int64(pqRequired), 4: []byte("open_time"), 6: int64(pqTimestamp), 10: timeType},
+		{1: int64(pqDouble), 3: int64(pqRequired), 4: []byte("close")},
+	}
+	for i, want := range wantSchema {
+		if got := schema[i+1]; !reflect.DeepEqual(got, want) {
+			t.Errorf("schema column %d: want %v, got %v", i, want, got)
+		}
+	}
+
+	// RowGroup columns, total size and rows, the chunks follow each other
+	// from the magic to the footer
+	groups := meta[4].([]any)
+	wantRows := []int64{2, 1}
+	if len(groups) != len(wantRows) {
+		t.Fatalf("row groups: want %d, got %d", len(wantRows), len(groups))
+	}
+	offset := int64(len(parquetMagic))
+	var row int
+	for g, rg := range groups {
+		group := rg.(thriftFields)
+		if group[3] != wantRows[g] {
+			t.Errorf("row group %d: want %d rows, got %v", g, wantRows[g], group[3])
+		}
+		var total int64
+		for j, col := range group[1].([]any) {
+			chunk := col.(thriftFields)
+			cm := chunk[3].(thriftFields)
+			size := cm[7].(int64)
+			want := thriftFields{
+				1: schema[j+1].(thriftFields)[1],
+				2: []any{int64(pqPlain), int64(pqRLE)},
+				3: []any{schema[j+1].(thriftFields)[4]},
+				4: int64(0),
+				5: wantRows[g],
+				6: size,
+				7: size,
+				9: offset,
+			}
+			if chunk[2] != offset || !reflect.DeepEqual(cm, want) {
+				t.Errorf("row group %d column %d: want %v at %d, got %v at %v", g, j, want, offset, cm, chunk[2])
+			}
+			checkParquetPage(t, f[offset:offset+size], cs[row:row+int(wantRows[g])], j)
+			offset += size
+			total += size
+		}
+		if group[2] != total {
+			t.Errorf("row group %d: want %d bytes, got %v", g, total, group[2])
+		}
+		row += int(wantRows[g])
+	}
+	if offset != footerStart {
+		t.Errorf("row groups: want the end at the footer %d, got %d", footerStart, offset)
+	}
+
+	kv := map[string]string{}
+	for _, e := range meta[5].([]any) {
+		kv[string(e.(thriftFields)[1].([]byte))] = string(e.(thriftFields)[2].([]byte))
+	}
+	wantKV := map[string]string{"symbol": "BTCUSDT", "interval": "1s", "exchange": "binance"}
+	if !reflect.DeepEqual(kv, wantKV) {
+		t.Errorf("key value metadata: want %v, got %v", wantKV, kv)
+	}
+}
+
+// Check the page of a column chunk has the values of the candles,
+// the column 0 is the open time and 1 the close price
+func checkParquetPage(t *testing.T, chunk []byte, cs []Candle, col int) {
+	r := &thriftReader{b: chunk}
+	page := r.structure()
+	data := r.b
+	want := thriftFields{
+		1: int64(pqDataPage),
+		2: int64(len(data)),
+		3: int64(len(data)),
+		5: thriftFields{1: int64(len(cs)), 2: int64(pqPlain), 3: int64(pqRLE), 4: int64(pqRLE)},
+	}
+	if r.err != nil || !reflect.DeepEqual(page, want) {
+		t.Errorf("page header: want %v, got %v %v", want, page, r.err)
+		return
+	}
+	if len(data) != 8*len(cs) {
+		t.Errorf("page values: want %d bytes, got %d", 8*len(cs), len(data))
+		return
+	}
+	for i := range cs {
+		v := binary.LittleEndian.Uint64(data[8*i:])
+		if col == 0 && int64(v) != SecToMilli(cs[i].OTime) {
+			t.Errorf("open time %d: want %d, got %d", i, SecToMilli(cs[i].OTime), v)
+		}
+		if col == 1 && math.Float64frombits(v) != cs[i].CPrice {
+			t.Errorf("close %d: want %v, got %v", i, cs[i].CPrice, math.Float64frombits(v))
+		}
+	}
+}
+
+// A decoded thrift struct, the values are int64, []byte, bool, []any and thriftFields
+type thriftFields map[int16]any
+
+// Decodes the thrift compact protocol of the types the writer uses
+type thriftReader struct {
+	b   []byte
+	err error
+}
+
+func (r *thriftReader) byte() byte {
+	if len(r.b) == 0 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	c := r.b[0]
+	r.b = r.b[1:]
+	return c
+}
+
+func (r *thriftReader) varint() int64 {
+	v, n := binary.Varint(r.b)
+	if n <= 0 {
+		r.err = io.ErrUnexpectedEOF
+		r.b = nil
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b)
+	if n <= 0 {
+		r.err = io.ErrUnexpectedEOF
+		r.b = nil
+		return 0
+	}
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *thriftReader) structure() thriftFields {
+	fields := thriftFields{}
+	var id int16
+	for r.err == nil {
+		h := r.byte()
+		if h == 0 {
+			break
+		}
+		if d := int16(h >> 4); d != 0 {
+			id += d
+		} else {
+			id = int16(r.varint())
+		}
+		fields[id] = r.value(h & 0x0f)
+	}
+	return fields
+}
+
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case thriftTrue:
+		return true
+	case thriftFalse:
+		return false
+	case thriftI32, thriftI64:
+		return r.varint()
+	case thriftBinary:
+		n := r.uvarint()
+		if uint64(len(r.b)) < n {
+			r.err = io.ErrUnexpectedEOF
+			return nil
+		}
+		v := r.b[:n]
+		r.b = r.b[n:]
+		return v
+	case thriftList:
+		h := r.byte()
+		n := uint64(h >> 4)
+		if n == 15 {
+			n = r.uvarint()
+		}
+		list := []any{}
+		for i := uint64(0); i < n && r.err == nil; i++ {
+			list = append(list, r.value(h&0x0f))
+		}
+		return list
+	case thriftStruct:
+		return r.structure()
+	}
+	r.err = fmt.Errorf("thrift type %d", typ)
+	return nil
+}
@@ -1,13 +1,17 @@
 package candles
 
 import (
+	"errors"
 	"unsafe"
 
 	"github.com/valyala/fastjson"
 	"github.com/valyala/fastjson/fastfloat"
 )
 
-var parser = &fastjson.Parser{}
+var errBinanceFields = errors.New("parse candle: too few fields")
+
+// a parser is not safe for concurrent use, loaders of many symbols take their own
+var parserPool fastjson.ParserPool
 
 // b2s converts byte slice to a string without memory allocation.
 // See https://groups.google.com/forum/#!msg/Golang-Nuts/ENgbUzYvCuU/90yGx7GUAgAJ .
@@ -23,7 +27,10 @@ func parseFloat(v *fastjson.Value) (float64, error) {
 	return fastfloat.Parse(b2s(b))
 }
 
-func parseCandles(b []byte, dst *Candles) (int, error) {
+// Parse Binance klines into dst, the candles which do not fit are skipped
+func parseCandles(b []byte, dst []Candle) (int, error) {
+	parser := parserPool.Get()
+	defer parserPool.Put(parser)
 	v, err := parser.ParseBytes(b)
 	if err != nil {
 		return 0, errorWrap("parse bytes", err)
@@ -33,13 +40,24 @@ func parseCandles(b []byte, dst *Candles) (int, error) {
 		return 0, errorWrap("parse candles", err)
 	}
 	var i int
-	for i = 0; i < len(candles); i++ {
+	for i = 0; i < len(candles) && i < len(dst); i++ {
 		c, err := candles[i].Array()
 		if err != nil {
 			return 0, errorWrap("parse candle", err)
 		}
+		// the fields up to the taker buy quote volume
+		if len(c) < 11 {
+			return 0, errBinanceFields
+		}
+
+		t, err := c[0].Int64()
+		if err != nil {
+			return 0, errorWrap("parse open time", err)
+		}
+		// Milli to seconds
+		dst[i].OTime = uint32(t / 1000)
 
-		t, err := c[6].Int64()
+		t, err = c[6].Int64()
 		if err != nil {
 			return 0, errorWrap("parse close time", err)
 		}
@@ -51,29 +69,59 @@ func parseCandles(b []byte, dst *Candles) (int, error) {
 		// Milli to seconds
 		dst[i].CTime = uint32(t/1000) + 1
 
-		p, err := parseFloat(c[2])
+		p, err := parseFloat(c[1])
+		if err != nil {
+			return 0, errorWrap("parse open price", err)
+		}
+		dst[i].OPrice = p
+
+		p, err = parseFloat(c[2])
 		if err != nil {
 			return 0, errorWrap("parse high price", err)
 		}
-		dst[i].HPrice = float32(p)
+		dst[i].HPrice = p
 
 		p, err = parseFloat(c[3])
 		if err != nil {
 			return 0, errorWrap("parse low price", err)
 		}
-		dst[i].LPrice = float32(p)
+		dst[i].LPrice = p
 
 		p, err = parseFloat(c[4])
 		if err != nil {
 			return 0, errorWrap("parse close price", err)
 		}
-		dst[i].CPrice = float32(p)
+		dst[i].CPrice = p
 
 		p, err = parseFloat(c[5])
 		if err != nil {
 			return 0, errorWrap("parse volume", err)
 		}
-		dst[i].Volume = float32(p)
+		dst[i].Volume = p
+
+		p, err = parseFloat(c[7])
+		if err != nil {
+			return 0, errorWrap("parse quote volume", err)
+		}
+		dst[i].QVolume = p
+
+		t, err = c[8].Int64()
+		if err != nil {
+			return 0, errorWrap("parse number of trades", err)
+		}
+		dst[i].Trades = uint32(t)
+
+		p, err = parseFloat(c[9])
+		if err != nil {
+			return 0, errorWrap("parse taker buy base volume", err)
+		}
+		dst[i].TBVolume = p
+
+		p, err = parseFloat(c[10])
+		if err != nil {
+			return 0, errorWrap("parse taker buy quote volume", err)
+		}
+		dst[i].TQVolume = p
 	}
 	return i, nil
 }
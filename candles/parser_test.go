@@ -1,6 +1,9 @@
 package candles
 
-import "testing"
+import (
+	"errors"
+	"testing"
+)
 
 func TestCandlesParser(t *testing.T) {
 	jsonData := `[
@@ -12,7 +15,7 @@ func TestCandlesParser(t *testing.T) {
 	const prefix = "parse candles"
 
 	var cs Candles
-	n, err := parseCandles([]byte(jsonData), &cs)
+	n, err := parseCandles([]byte(jsonData), cs[:])
 	if err != nil {
 		t.Errorf("%s: %s", prefix, err.Error())
 	}
@@ -20,19 +23,47 @@ func TestCandlesParser(t *testing.T) {
 		t.Errorf("%s: want len parsed 3, got: %d", prefix, n)
 	}
 
-	// float32 has low precision, test may cause the error
-	var wantClosePrice float32 = 2507.23
+	var wantClosePrice float64 = 2507.23
 	if cs[0].CPrice != wantClosePrice {
 		t.Errorf("%s: want close price %f, got: %f", prefix, wantClosePrice, cs[0].CPrice)
 	}
 
-	var wantVolume float32 = 5.1033
+	var wantOpenPrice float64 = 2507.23
+	if cs[1].OPrice != wantOpenPrice {
+		t.Errorf("%s: want open price %f, got: %f", prefix, wantOpenPrice, cs[1].OPrice)
+	}
+
+	var wantVolume float64 = 5.1033
 	if cs[1].Volume != wantVolume {
 		t.Errorf("%s: want volume %f, got: %f", prefix, wantVolume, cs[1].Volume)
 	}
 
+	var wantOpenTime uint32 = 1707696002
+	if cs[2].OTime != wantOpenTime {
+		t.Errorf("%s: want open time %d, got: %d", prefix, wantOpenTime, cs[2].OTime)
+	}
+
+	var wantTrades uint32 = 17
+	if cs[1].Trades != wantTrades {
+		t.Errorf("%s: want trades %d, got: %d", prefix, wantTrades, cs[1].Trades)
+	}
+
+	var wantTBVolume float64 = 0.2144
+	if cs[2].TBVolume != wantTBVolume {
+		t.Errorf("%s: want taker buy volume %f, got: %f", prefix, wantTBVolume, cs[2].TBVolume)
+	}
+
 	var wantCloseTime uint32 = 1707696003
 	if cs[2].CTime != wantCloseTime {
 		t.Errorf("%s: want close time %d, got: %d", prefix, wantCloseTime, cs[2].CTime)
 	}
 }
+
+func TestCandlesParserShortRow(t *testing.T) {
+	// the row ends after the close time
+	jsonData := `[[1707696000000,"2507.22000000","2507.23000000","2507.21000000","2507.23000000","1.11450000",1707696000999]]`
+	var cs Candles
+	if _, err := parseCandles([]byte(jsonData), cs[:]); !errors.Is(err, errBinanceFields) {
+		t.Errorf("short row: want error '%s', got '%v'", errBinanceFields, err)
+	}
+}
@@ -5,8 +5,13 @@ import (
 )
 
 const (
-	apiUriBase     = "https://api.binance.com/api/v3/klines"
-	apiQueryString = "&interval=1s&limit=1000&startTime=" // 1677369601000
+	apiBaseURL    = "https://api.binance.com"
+	apiKlinesPath = "/api/v3/klines"
+	apiLimit      = "&limit="
+	apiStartTime  = "&startTime=" // 1677369601000
+	apiEndTime    = "&endTime="
+	// request weight of the klines endpoint
+	klinesWeight = 2
 )
 
 type Query struct {
@@ -14,12 +19,22 @@ type Query struct {
 	buf     []byte
 }
 
-func (q *Query) Init(symbol string) {
-	q.buf = make([]byte, 0, len(apiQueryString)*2)
-	q.buf = append(q.buf, "symbol="...)
+// The param is the name of the symbol parameter of the endpoint
+func (q *Query) init(param, symbol string, interval Interval, endTime int64, limit int) {
+	q.buf = make([]byte, 0, 128)
+	q.buf = append(q.buf, param...)
+	q.buf = append(q.buf, '=')
 	// symbol already is upper case
 	q.buf = append(q.buf, symbol...)
-	q.buf = append(q.buf, apiQueryString...)
+	q.buf = append(q.buf, "&interval="...)
+	q.buf = append(q.buf, interval...)
+	if endTime > 0 {
+		q.buf = append(q.buf, apiEndTime...)
+		q.buf = strconv.AppendInt(q.buf, endTime, 10)
+	}
+	q.buf = append(q.buf, apiLimit...)
+	q.buf = strconv.AppendInt(q.buf, int64(limit), 10)
+	q.buf = append(q.buf, apiStartTime...)
 	q.baseLen = len(q.buf)
 }
 
@@ -28,3 +43,11 @@ func (q *Query) QueryStringBytes(startTime int64) []byte {
 	q.buf = append(q.buf[:q.baseLen], t...)
 	return q.buf
 }
+
+// Same as QueryStringBytes with the end time of the query initialized without it
+func (q *Query) rangeBytes(startTime, endTime int64) []byte {
+	q.buf = strconv.AppendInt(q.buf[:q.baseLen], startTime, 10)
+	q.buf = append(q.buf, apiEndTime...)
+	q.buf = strconv.AppendInt(q.buf, endTime, 10)
+	return q.buf
+}
@@ -4,7 +4,7 @@ import "testing"
 
 func TestQueryStringBuild(t *testing.T) {
 	q := Query{}
-	q.Init("ETHUSDT")
+	q.init("symbol", "ETHUSDT", Interval1s, 0, len(Candles{}))
 	want := "symbol=ETHUSDT&interval=1s&limit=1000&startTime=1677369601000"
 	var timestamp int64 = 1677369601000
 	got := string(q.QueryStringBytes(timestamp))
@@ -12,3 +12,23 @@ func TestQueryStringBuild(t *testing.T) {
 		t.Errorf("query build want: %s, got %s", want, got)
 	}
 }
+
+func TestQueryStringInterval(t *testing.T) {
+	q := Query{}
+	q.init("symbol", "BTCUSDT", Interval1M, 0, len(Candles{}))
+	want := "symbol=BTCUSDT&interval=1M&limit=1000&startTime=1677369601000"
+	got := string(q.QueryStringBytes(1677369601000))
+	if got != want {
+		t.Errorf("query build want: %s, got %s", want, got)
+	}
+}
+
+func TestQueryStringEndTime(t *testing.T) {
+	q := Query{}
+	q.init("symbol", "BTCUSDT", Interval1m, 1680307199999, len(Candles{}))
+	want := "symbol=BTCUSDT&interval=1m&endTime=1680307199999&limit=1000&startTime=1677369601000"
+	got := string(q.QueryStringBytes(1677369601000))
+	if got != want {
+		t.Errorf("query build want: %s, got %s", want, got)
+	}
+}
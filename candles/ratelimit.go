@@ -0,0 +1,130 @@
+package candles
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	// request weight allowed by Binance per minute and IP
+	DefaultWeightLimit = 6000
+	// the same for USD-M and COIN-M futures
+	FuturesWeightLimit = 2400
+	headerUsedWeight   = "X-Mbx-Used-Weight-1m"
+	headerRetryAfter   = "Retry-After"
+)
+
+// The exchange answered with 429 or 418, the request is repeated after the pause it asked for
+var ErrRateLimited = errors.New("rate limited")
+
+// Keeps the request weight of one or many loaders under the limit of the exchange.
+// The weight used in the current minute is taken from the response headers,
+// a loader waits for the next minute instead of going over the limit
+type RateLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	used   int
+	window time.Time // the minute the used weight belongs to
+	until  time.Time // no requests before, set by Retry-After
+	now    func() time.Time
+}
+
+func NewRateLimiter(limit int) *RateLimiter {
+	if limit <= 0 {
+		limit = DefaultWeightLimit
+	}
+	return &RateLimiter{limit: limit, now: time.Now}
+}
+
+// Returns how long to wait before a request of the weight may be sent,
+// the weight is reserved when there is no need to wait
+func (l *RateLimiter) delay(weight int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.roll(now)
+	var d time.Duration
+	if l.until.After(now) {
+		d = l.until.Sub(now)
+	}
+	// a weight above the limit would never fit, let the exchange decide
+	if l.used > 0 && l.used+weight > l.limit {
+		if next := l.window.Add(time.Minute).Sub(now); next > d {
+			d = next
+		}
+	}
+	if d == 0 {
+		l.used += weight
+	}
+	return d
+}
+
+// Start counting from zero in a new minute
+func (l *RateLimiter) roll(now time.Time) {
+	if w := now.Truncate(time.Minute); !w.Equal(l.window) {
+		l.window = w
+		l.used = 0
+	}
+}
+
+// Block until a request of the weight may be sent, the waiting is stopped by a signal
+func (l *RateLimiter) wait(weight int, intChan chan os.Signal) error {
+	for {
+		d := l.delay(weight)
+		if d == 0 {
+			return nil
+		}
+		select {
+		case <-intChan:
+			return ErrInterrupted
+		case <-time.After(d):
+		}
+	}
+}
+
+// Take the used weight and the pause asked by the exchange from a response
+func (l *RateLimiter) update(h *fasthttp.ResponseHeader) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.roll(now)
+	if v := peekHeader(h, headerUsedWeight); v != nil {
+		// requests of other loaders may be reserved but not counted by the exchange yet
+		if used, err := strconv.Atoi(b2s(v)); err == nil && used > l.used {
+			l.used = used
+		}
+	}
+
+	code := h.StatusCode()
+	if code != fasthttp.StatusTooManyRequests && code != fasthttp.StatusTeapot {
+		return
+	}
+	until := l.window.Add(time.Minute)
+	if v := peekHeader(h, headerRetryAfter); v != nil {
+		if sec, err := strconv.Atoi(b2s(v)); err == nil {
+			until = now.Add(time.Duration(sec) * time.Second)
+		}
+	}
+	if until.After(l.until) {
+		l.until = until
+	}
+}
+
+// Header names are not normalized by the client, compare them ignoring the case
+func peekHeader(h *fasthttp.ResponseHeader, name string) []byte {
+	var v []byte
+	h.VisitAll(func(key, value []byte) {
+		if v == nil && bytes.EqualFold(key, []byte(name)) {
+			v = value
+		}
+	})
+	return v
+}
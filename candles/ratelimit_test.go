@@ -0,0 +1,55 @@
+package candles
+
+import (
+	"testing"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+func TestRateLimiterWeight(t *testing.T) {
+	now := time.Date(2024, 2, 19, 10, 0, 20, 0, time.UTC)
+	l := NewRateLimiter(10)
+	l.now = func() time.Time { return now }
+
+	if d := l.delay(4); d != 0 {
+		t.Errorf("first request: want no delay, got %s", d)
+	}
+
+	h := &fasthttp.ResponseHeader{}
+	h.DisableNormalizing()
+	h.Set("x-mbx-used-weight-1m", "8")
+	l.update(h)
+	if l.used != 8 {
+		t.Errorf("used weight from header: want 8, got %d", l.used)
+	}
+
+	// 8 + 4 is over the limit, wait for the next minute
+	if d := l.delay(4); d != 40*time.Second {
+		t.Errorf("over the limit: want delay 40s, got %s", d)
+	}
+
+	now = now.Add(40 * time.Second)
+	if d := l.delay(4); d != 0 {
+		t.Errorf("next minute: want no delay, got %s", d)
+	}
+}
+
+func TestRateLimiterRetryAfter(t *testing.T) {
+	now := time.Date(2024, 2, 19, 10, 0, 20, 0, time.UTC)
+	l := NewRateLimiter(DefaultWeightLimit)
+	l.now = func() time.Time { return now }
+
+	h := &fasthttp.ResponseHeader{}
+	h.SetStatusCode(fasthttp.StatusTeapot)
+	h.Set(headerRetryAfter, "120")
+	l.update(h)
+
+	if d := l.delay(klinesWeight); d != 2*time.Minute {
+		t.Errorf("banned: want delay 2m, got %s", d)
+	}
+	now = now.Add(2 * time.Minute)
+	if d := l.delay(klinesWeight); d != 0 {
+		t.Errorf("ban is over: want no delay, got %s", d)
+	}
+}
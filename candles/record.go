@@ -0,0 +1,143 @@
+package candles
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// The set of candle fields written to a data file
+type Layout uint8
+
+const (
+	// open and close time, OHLC prices and volume
+	LayoutCompact Layout = iota
+	// compact fields plus quote volume, number of trades and taker buy volumes
+	LayoutExtended
+)
+
+// The way prices and volumes are written to a data file
+type Encoding uint8
+
+const (
+	// 4 bytes per value, compact but loses digits on big prices and volumes
+	EncodingFloat32 Encoding = iota
+	// 8 bytes per value, keeps every digit the exchange sends
+	EncodingFloat64
+)
+
+var ErrInvalidEncoding = errors.New("invalid encoding")
+
+// Parse the encoding name as used in the command line
+func ParseEncoding(s string) (Encoding, error) {
+	switch s {
+	case "float32":
+		return EncodingFloat32, nil
+	case "float64":
+		return EncodingFloat64, nil
+	}
+	return 0, errorWrap(s, ErrInvalidEncoding)
+}
+
+func (e Encoding) String() string {
+	if e == EncodingFloat64 {
+		return "float64"
+	}
+	return "float32"
+}
+
+// Describes how candles are encoded in a data file
+type Format struct {
+	Layout   Layout
+	Encoding Encoding
+}
+
+// Returns the number of bytes used by one candle
+func (f Format) RecordSize() int {
+	w := f.floatSize()
+	// open and close times are always 4 bytes
+	size := 2*4 + 5*w
+	if f.Layout == LayoutExtended {
+		// 4 bytes for the number of trades
+		size += 3*w + 4
+	}
+	return size
+}
+
+func (f Format) valid() bool {
+	return f.Layout <= LayoutExtended && f.Encoding <= EncodingFloat64
+}
+
+func (f Format) floatSize() int {
+	if f.Encoding == EncodingFloat64 {
+		return 8
+	}
+	return 4
+}
+
+// Write v to the start of bs and return the number of bytes written
+func (f Format) putFloat(bs []byte, v float64) int {
+	if f.Encoding == EncodingFloat64 {
+		binary.LittleEndian.PutUint64(bs, math.Float64bits(v))
+		return 8
+	}
+	binary.LittleEndian.PutUint32(bs, math.Float32bits(float32(v)))
+	return 4
+}
+
+// Read a value from the start of bs and return it with the number of bytes read
+func (f Format) float(bs []byte) (float64, int) {
+	if f.Encoding == EncodingFloat64 {
+		return math.Float64frombits(binary.LittleEndian.Uint64(bs)), 8
+	}
+	return float64(math.Float32frombits(binary.LittleEndian.Uint32(bs))), 4
+}
+
+// Write one candle to bs, bs must be at least RecordSize long
+func (f Format) encode(c *Candle, bs []byte) {
+	binary.LittleEndian.PutUint32(bs[:4], c.OTime)
+	binary.LittleEndian.PutUint32(bs[4:8], c.CTime)
+	off := 8
+	off += f.putFloat(bs[off:], c.OPrice)
+	off += f.putFloat(bs[off:], c.HPrice)
+	off += f.putFloat(bs[off:], c.LPrice)
+	off += f.putFloat(bs[off:], c.CPrice)
+	off += f.putFloat(bs[off:], c.Volume)
+	if f.Layout != LayoutExtended {
+		return
+	}
+	off += f.putFloat(bs[off:], c.QVolume)
+	binary.LittleEndian.PutUint32(bs[off:off+4], c.Trades)
+	off += 4
+	off += f.putFloat(bs[off:], c.TBVolume)
+	f.putFloat(bs[off:], c.TQVolume)
+}
+
+// Read one candle from bs, the extended fields are zero for the compact layout
+func (f Format) decode(bs []byte, c *Candle) {
+	c.OTime = binary.LittleEndian.Uint32(bs[:4])
+	c.CTime = binary.LittleEndian.Uint32(bs[4:8])
+	off := 8
+	var n int
+	c.OPrice, n = f.float(bs[off:])
+	off += n
+	c.HPrice, n = f.float(bs[off:])
+	off += n
+	c.LPrice, n = f.float(bs[off:])
+	off += n
+	c.CPrice, n = f.float(bs[off:])
+	off += n
+	c.Volume, n = f.float(bs[off:])
+	off += n
+	if f.Layout != LayoutExtended {
+		c.QVolume, c.Trades, c.TBVolume, c.TQVolume = 0, 0, 0, 0
+		return
+	}
+	c.QVolume, n = f.float(bs[off:])
+	off += n
+	c.Trades = binary.LittleEndian.Uint32(bs[off : off+4])
+	off += 4
+	c.TBVolume, n = f.float(bs[off:])
+	off += n
+	c.TQVolume, _ = f.float(bs[off:])
+}
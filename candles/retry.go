@@ -0,0 +1,86 @@
+package candles
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"os"
+	"time"
+
+	"github.com/valyala/fasthttp"
+)
+
+const (
+	DefaultMaxRetries = 5
+	DefaultMinDelay   = 500 * time.Millisecond
+	DefaultMaxDelay   = 30 * time.Second
+	// a request hanging longer is retried as a timeout
+	requestTimeout = 30 * time.Second
+)
+
+// The exchange answered with 5xx, the request may succeed later
+var ErrServerStatus = errors.New("server error status")
+
+// How to repeat a request which failed because of the network or the server
+type RetryPolicy struct {
+	// the number of retries after the first attempt, 0 disables retries
+	MaxRetries int
+	// the delay before the first retry, it doubles with every next one
+	MinDelay time.Duration
+	// the upper bound of the delay
+	MaxDelay time.Duration
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries: DefaultMaxRetries,
+		MinDelay:   DefaultMinDelay,
+		MaxDelay:   DefaultMaxDelay,
+	}
+}
+
+// Returns the delay before the retry number attempt (from 0), the exponential
+// delay is jittered in the upper half so parallel loaders do not retry in step
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.MaxDelay
+	// stop doubling before overflow
+	if attempt < 32 {
+		if e := p.MinDelay << attempt; e > 0 && e < d {
+			d = e
+		}
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// Errors which may go away on the next attempt
+func isTransient(err error) bool {
+	if errors.Is(err, ErrServerStatus) ||
+		errors.Is(err, ErrRateLimited) ||
+		errors.Is(err, fasthttp.ErrTimeout) ||
+		errors.Is(err, fasthttp.ErrConnectionClosed) ||
+		errors.Is(err, fasthttp.ErrDialTimeout) {
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne)
+}
+
+// Call fn until it succeeds, fails with a permanent error or the retries run out.
+// Waiting for the next attempt is stopped by a signal
+func retry(p RetryPolicy, intChan chan os.Signal, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || !isTransient(err) || attempt >= p.MaxRetries {
+			return err
+		}
+		select {
+		case <-intChan:
+			return ErrInterrupted
+		case <-time.After(p.backoff(attempt)):
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package candles
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	p := RetryPolicy{MaxRetries: 3, MinDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, max := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		max *= time.Millisecond
+		d := p.backoff(attempt)
+		if d < max/2 || d > max {
+			t.Errorf("backoff %d: want between %s and %s, got %s", attempt, max/2, max, d)
+		}
+	}
+	if d := p.backoff(100); d < p.MaxDelay/2 || d > p.MaxDelay {
+		t.Errorf("backoff overflow: want at most %s, got %s", p.MaxDelay, d)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	p := RetryPolicy{MaxRetries: 2, MinDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	intChan := make(chan os.Signal, 1)
+
+	var calls int
+	err := retry(p, intChan, func() error {
+		calls++
+		return ErrServerStatus
+	})
+	if !errors.Is(err, ErrServerStatus) || calls != 3 {
+		t.Errorf("transient error: want 3 calls and '%s', got %d and '%v'", ErrServerStatus, calls, err)
+	}
+
+	calls = 0
+	errPermanent := errors.New("bad request")
+	err = retry(p, intChan, func() error {
+		calls++
+		return errPermanent
+	})
+	if err != errPermanent || calls != 1 {
+		t.Errorf("permanent error: want 1 call, got %d and '%v'", calls, err)
+	}
+
+	calls = 0
+	err = retry(p, intChan, func() error {
+		calls++
+		if calls < 2 {
+			return ErrServerStatus
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("recovered: want 2 calls, got %d and '%v'", calls, err)
+	}
+
+	intChan <- os.Interrupt
+	err = retry(p, intChan, func() error { return ErrServerStatus })
+	if err != ErrInterrupted {
+		t.Errorf("interrupted: want '%s', got '%v'", ErrInterrupted, err)
+	}
+}
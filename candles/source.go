@@ -0,0 +1,85 @@
+package candles
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"strings"
+)
+
+const (
+	ExchangeBinance = "binance"
+	ExchangeBybit   = "bybit"
+)
+
+var ErrUnknownExchange = errors.New("unknown exchange")
+
+// A range of candles to fetch
+type BatchRequest struct {
+	Symbol   string
+	Interval Interval
+	// milli seconds, the first candle opens not earlier
+	Start int64
+	// milli seconds, candles open before it, 0 means no limit
+	End int64
+}
+
+// An exchange API the candles are downloaded from
+type Source interface {
+	// The exchange name stored in the file header
+	Name() string
+	// The number of candles a single Fetch may return
+	BatchSize() int
+	// Load candles of the request into dst sorted by the open time.
+	// Fewer candles than len(dst) mean there are no more of them for now
+	Fetch(r *BatchRequest, dst []Candle) (int, error)
+	// Release the connections
+	Close()
+}
+
+var sources = map[string]func(opts *LoadOptions, intChan chan os.Signal) (Source, error){
+	ExchangeBinance: newBinanceSource(binanceSpot),
+	ExchangeBybit:   newBybitSource,
+
+	ExchangeBinanceUSDM:        newBinanceSource(binanceUSDM),
+	ExchangeBinanceUSDMMark:    newBinanceSource(binanceUSDMMark),
+	ExchangeBinanceUSDMIndex:   newBinanceSource(binanceUSDMIndex),
+	ExchangeBinanceUSDMPremium: newBinanceSource(binanceUSDMPremium),
+
+	ExchangeBinanceCOINM:        newBinanceSource(binanceCOINM),
+	ExchangeBinanceCOINMMark:    newBinanceSource(binanceCOINMMark),
+	ExchangeBinanceCOINMIndex:   newBinanceSource(binanceCOINMIndex),
+	ExchangeBinanceCOINMPremium: newBinanceSource(binanceCOINMPremium),
+}
+
+// Returns the request weight per minute allowed by the exchange
+func WeightLimit(exchange string) int {
+	if strings.HasPrefix(exchange, ExchangeBinance+"-") {
+		return FuturesWeightLimit
+	}
+	return DefaultWeightLimit
+}
+
+// Returns the names of the supported exchanges
+func Exchanges() []string {
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Create the source of the exchange in the options, an empty one is Binance.
+// The signal stops waiting for a retry or for the rate limit
+func NewSource(opts *LoadOptions, intChan chan os.Signal) (Source, error) {
+	exchange := opts.Exchange
+	if exchange == "" {
+		exchange = DefaultExchange
+	}
+	newSource, ok := sources[exchange]
+	if !ok {
+		return nil, errorWrap(exchange, ErrUnknownExchange)
+	}
+	return newSource(opts, intChan)
+}
@@ -0,0 +1,265 @@
+package candles
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Start a local plain HTTP server and point the options to it
+func testServer(t *testing.T, opts *LoadOptions, h http.HandlerFunc) {
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+	opts.BaseURL = ts.URL
+}
+
+func testLoadOptions() *LoadOptions {
+	opts := DefaultLoadOptions("BTCUSDT")
+	opts.Interval = Interval1m
+	opts.Retry = RetryPolicy{MaxRetries: 2, MinDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	return opts
+}
+
+func TestNewSource(t *testing.T) {
+	opts := testLoadOptions()
+	for _, name := range Exchanges() {
+		opts.Exchange = name
+		src, err := NewSource(opts, nil)
+		if err != nil {
+			t.Fatalf("new source %s: %s", name, err.Error())
+		}
+		if src.Name() != name {
+			t.Errorf("source name: want %s, got %s", name, src.Name())
+		}
+		src.Close()
+	}
+
+	opts.Exchange = "mtgox"
+	if _, err := NewSource(opts, nil); !errors.Is(err, ErrUnknownExchange) {
+		t.Errorf("unknown exchange: want error '%s', got '%v'", ErrUnknownExchange, err)
+	}
+
+	opts.Exchange = ExchangeBinance
+	for _, base := range []string{"api.binance.com", "ftp://api.binance.com", "https://"} {
+		opts.BaseURL = base
+		if _, err := NewSource(opts, nil); !errors.Is(err, ErrInvalidBaseURL) {
+			t.Errorf("base url %s: want error '%s', got '%v'", base, ErrInvalidBaseURL, err)
+		}
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	opts := testLoadOptions()
+	t.Setenv(EnvBaseURL, "")
+	if got := baseURL(opts, apiBaseURL); got != apiBaseURL {
+		t.Errorf("default: want %s, got %s", apiBaseURL, got)
+	}
+	t.Setenv(EnvBaseURL, "https://testnet.binance.vision/")
+	if got := baseURL(opts, apiBaseURL); got != "https://testnet.binance.vision" {
+		t.Errorf("environment: want https://testnet.binance.vision, got %s", got)
+	}
+	// the spot mirror is not used for the futures
+	t.Setenv(EnvUSDMBaseURL, "")
+	if got := baseURL(opts, fapiBaseURL); got != fapiBaseURL {
+		t.Errorf("futures default: want %s, got %s", fapiBaseURL, got)
+	}
+	t.Setenv(EnvUSDMBaseURL, "https://testnet.binancefuture.com")
+	if got := baseURL(opts, fapiBaseURL); got != "https://testnet.binancefuture.com" {
+		t.Errorf("futures environment: want https://testnet.binancefuture.com, got %s", got)
+	}
+	opts.BaseURL = "https://api.binance.us"
+	if got := baseURL(opts, apiBaseURL); got != opts.BaseURL {
+		t.Errorf("options: want %s, got %s", opts.BaseURL, got)
+	}
+
+	c, err := newHTTPClient(apiBaseURL, apiKlinesPath, opts, nil)
+	if err != nil {
+		t.Fatalf("new client: %s", err.Error())
+	}
+	if !c.hc.IsTLS || c.hc.Addr != "api.binance.us:443" || string(c.uri.Path()) != apiKlinesPath {
+		t.Errorf("https client: got addr %s, tls %v, path %s", c.hc.Addr, c.hc.IsTLS, c.uri.Path())
+	}
+	opts.BaseURL = "http://127.0.0.1:8080/mock"
+	c, err = newHTTPClient(apiBaseURL, apiKlinesPath, opts, nil)
+	if err != nil {
+		t.Fatalf("new client: %s", err.Error())
+	}
+	if c.hc.IsTLS || c.hc.Addr != "127.0.0.1:8080" || string(c.uri.Path()) != "/mock"+apiKlinesPath {
+		t.Errorf("http client: got addr %s, tls %v, path %s", c.hc.Addr, c.hc.IsTLS, c.uri.Path())
+	}
+}
+
+func TestBinanceSource(t *testing.T) {
+	opts := testLoadOptions()
+	var calls int
+	testServer(t, opts, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			// the first attempt fails on the server side and is retried
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		q := r.URL.Query()
+		if r.URL.Path != "/api/v3/klines" || q.Get("symbol") != "BTCUSDT" || q.Get("interval") != "1m" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":-1121,"msg":"Invalid symbol."}`))
+			return
+		}
+		if q.Get("endTime") != "1708300919999" {
+			t.Errorf("end time: want 1708300919999, got %s", q.Get("endTime"))
+		}
+		w.Header().Set("x-mbx-used-weight-1m", "12")
+		fmt.Fprintf(w, `[
+			[%s,"1.1","1.3","1.0","1.2","10.5",1708300859999,"12.6",3,"5.0","6.0","0"],
+			[1708300860000,"1.2","1.4","1.1","1.3","20.5",1708300919999,"26.6",4,"7.0","8.0","0"]
+		]`, q.Get("startTime"))
+	})
+	s, err := newBinanceSource(binanceSpot)(opts, make(chan os.Signal, 1))
+	if err != nil {
+		t.Fatalf("new source: %s", err.Error())
+	}
+	src := s.(*binanceSource)
+	defer src.Close()
+
+	cs := make([]Candle, src.BatchSize())
+	r := &BatchRequest{Symbol: "BTCUSDT", Interval: Interval1m, Start: 1708300800000, End: 1708300920000}
+	n, err := src.Fetch(r, cs)
+	if err != nil {
+		t.Fatalf("fetch: %s", err.Error())
+	}
+	if n != 2 || calls != 2 {
+		t.Errorf("fetch: want 2 candles in 2 calls, got %d in %d", n, calls)
+	}
+	want := Candle{1.1, 1.3, 1.0, 1.2, 10.5, 1708300800, 1708300860, 12.6, 3, 5.0, 6.0}
+	if cs[0] != want {
+		t.Errorf("candle: want %#v, got %#v", want, cs[0])
+	}
+	if src.http.limiter.used != 12 {
+		t.Errorf("used weight: want 12, got %d", src.http.limiter.used)
+	}
+
+	r.Symbol = "NOPE"
+	_, err = src.Fetch(r, cs)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !apiErr.InvalidSymbol() {
+		t.Errorf("invalid symbol: want *APIError, got '%v'", err)
+	}
+}
+
+func TestBinanceFuturesSource(t *testing.T) {
+	opts := testLoadOptions()
+	opts.Exchange = ExchangeBinanceCOINMIndex
+	var start int64 = 1708300800000
+	var calls int
+	testServer(t, opts, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		q := r.URL.Query()
+		if r.URL.Path != "/dapi/v1/indexPriceKlines" || q.Get("pair") != "BTCUSD" || q.Get("limit") != "1500" {
+			t.Errorf("query: got %s?%s", r.URL.Path, r.URL.RawQuery)
+		}
+		from, _ := strconv.ParseInt(q.Get("startTime"), 10, 64)
+		to, _ := strconv.ParseInt(q.Get("endTime"), 10, 64)
+		if to-from >= coinmMaxRange {
+			t.Errorf("window: want less than %d, got %d", coinmMaxRange, to-from)
+		}
+		// one candle a window
+		fmt.Fprintf(w, `[[%d,"1.1","1.3","1.0","1.2","0",%d,"0",60,"0","0","0"]]`, from, from+86399999)
+	})
+	s, err := NewSource(opts, make(chan os.Signal, 1))
+	if err != nil {
+		t.Fatalf("new source: %s", err.Error())
+	}
+	defer s.Close()
+	src := s.(*binanceSource)
+	if src.Name() != ExchangeBinanceCOINMIndex || src.BatchSize() != futuresLimit {
+		t.Errorf("source: want %s of %d, got %s of %d", ExchangeBinanceCOINMIndex, futuresLimit, src.Name(), src.BatchSize())
+	}
+	if src.http.limiter.limit != FuturesWeightLimit {
+		t.Errorf("weight limit: want %d, got %d", FuturesWeightLimit, src.http.limiter.limit)
+	}
+	// three windows of 200 days
+	src.now = func() time.Time { return time.UnixMilli(start + 2*coinmMaxRange + 1) }
+
+	cs := make([]Candle, src.BatchSize())
+	r := &BatchRequest{Symbol: "BTCUSD", Interval: Interval1d, Start: start}
+	n, err := src.Fetch(r, cs)
+	if err != nil {
+		t.Fatalf("fetch: %s", err.Error())
+	}
+	if n != 3 || calls != 3 {
+		t.Errorf("fetch: want 3 candles in 3 windows, got %d in %d", n, calls)
+	}
+	if cs[1].OTime != uint32((start+coinmMaxRange)/1000) {
+		t.Errorf("second window: want open time %d, got %d", (start+coinmMaxRange)/1000, cs[1].OTime)
+	}
+
+	r.Interval = Interval1s
+	if _, err = src.Fetch(r, cs); !errors.Is(err, ErrInvalidInterval) {
+		t.Errorf("1s interval: want error '%s', got '%v'", ErrInvalidInterval, err)
+	}
+}
+
+func TestBybitSource(t *testing.T) {
+	opts := testLoadOptions()
+	var calls int
+	testServer(t, opts, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		q := r.URL.Query()
+		if q.Get("symbol") != "BTCUSDT" {
+			fmt.Fprint(w, `{"retCode":10001,"retMsg":"Not supported symbols","result":{}}`)
+			return
+		}
+		if q.Get("category") != "spot" || q.Get("interval") != "1" {
+			t.Errorf("query: got %s", r.URL.RawQuery)
+		}
+		from, _ := strconv.ParseInt(q.Get("start"), 10, 64)
+		// the newest candle goes first
+		fmt.Fprintf(w, `{"retCode":0,"retMsg":"OK","result":{"category":"spot","symbol":"BTCUSDT","list":[
+			["%d","1.2","1.4","1.1","1.3","20.5","26.6"],
+			["%d","1.1","1.3","1.0","1.2","10.5","12.6"]
+		]}}`, from+60000, from)
+	})
+	s, err := newBybitSource(opts, make(chan os.Signal, 1))
+	if err != nil {
+		t.Fatalf("new source: %s", err.Error())
+	}
+	src := s.(*bybitSource)
+	defer src.Close()
+	var start int64 = 1708300800000
+	// the second window is the last one
+	src.now = func() time.Time { return time.UnixMilli(start + 1500*60000) }
+
+	cs := make([]Candle, src.BatchSize())
+	r := &BatchRequest{Symbol: "BTCUSDT", Interval: Interval1m, Start: start}
+	n, err := src.Fetch(r, cs)
+	if err != nil {
+		t.Fatalf("fetch: %s", err.Error())
+	}
+	if n != 4 || calls != 2 {
+		t.Errorf("fetch: want 4 candles in 2 windows, got %d in %d", n, calls)
+	}
+	want := Candle{1.1, 1.3, 1.0, 1.2, 10.5, 1708300800, 1708300860, 12.6, 0, 0, 0}
+	if cs[0] != want {
+		t.Errorf("candle: want %#v, got %#v", want, cs[0])
+	}
+	if cs[2].OTime != uint32((start+1000*60000)/1000) {
+		t.Errorf("second window: want open time %d, got %d", (start+1000*60000)/1000, cs[2].OTime)
+	}
+
+	r.Symbol = "NOPE"
+	_, err = src.Fetch(r, cs)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != 10001 {
+		t.Errorf("invalid symbol: want *APIError, got '%v'", err)
+	}
+
+	r.Interval = Interval1s
+	if _, err = src.Fetch(r, cs); !errors.Is(err, ErrInvalidInterval) {
+		t.Errorf("1s interval: want error '%s', got '%v'", ErrInvalidInterval, err)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/binary"
 	"errors"
 	"io"
-	"math"
 	"os"
 	"path/filepath"
 )
@@ -14,63 +13,140 @@ const (
 	DefaultDirPerm  = 0744
 	DefaultDataDir  = "candles"
 	DefaultExt      = ".bin"
-	CandleByteSize  = 5 * 4 // 4 bytes for any field
 	flagNew         = os.O_RDWR | os.O_CREATE | os.O_TRUNC
 	flagAppend      = os.O_RDWR | os.O_APPEND
+	// candles encoded and written at once
+	saveBatch = 2048
 )
 
 type Candle struct {
-	HPrice float32
-	LPrice float32
-	CPrice float32
-	Volume float32
+	OPrice float64 // 0 is unknown, the migrated legacy candles have no open
+	HPrice float64
+	LPrice float64
+	CPrice float64
+	Volume float64
+	OTime  uint32
 	CTime  uint32
+	// stored only with the extended layout
+	QVolume  float64 // quote asset volume
+	Trades   uint32  // number of trades
+	TBVolume float64 // taker buy base asset volume
+	TQVolume float64 // taker buy quote asset volume
+}
+
+// When the written candles are committed to the disk
+type Durability uint8
+
+const (
+	// when the storage is closed or synced
+	SyncOnClose Durability = iota
+	// after every saved batch, an interrupted load loses at most the batch in progress
+	SyncPerBatch
+)
+
+var ErrInvalidDurability = errors.New("durability must be close or batch")
+
+// Parse the durability name of close or batch
+func ParseDurability(s string) (Durability, error) {
+	switch s {
+	case "close":
+		return SyncOnClose, nil
+	case "batch":
+		return SyncPerBatch, nil
+	}
+	return 0, errorWrap(s, ErrInvalidDurability)
 }
 
 type Storage struct {
 	fd       *os.File
-	readPos  int64
+	header   *Header
+	format   Format
+	size     int   // record size in bytes
+	offset   int64 // header size in bytes, candles start after it
+	readPos  int64 // position relative to the offset
 	readBuf  []byte
-	writeBuf [CandleByteSize]byte
+	writeBuf []byte
+	sync     Durability
+	dirty    bool // written after the last sync
+	aligned  bool // no partial record at the end, checked before the first write
+}
+
+// Create new file with default path made of the header symbol and interval
+func NewDefaultStorage(h Header) (*Storage, error) {
+	return defaultStorage(h.Exchange, h.Symbol, h.Interval, &h, flagNew)
 }
 
-// Create new file with default path
-func NewDefaultStorage(symbol string) (*Storage, error) {
-	return defaultStorage(symbol, flagNew)
+// Use an existing file with default path, the header is read from the file
+// and must belong to the exchange, the symbol and the interval
+func DefaultStorage(exchange, symbol string, interval Interval) (*Storage, error) {
+	s, err := defaultStorage(exchange, symbol, interval, nil, flagAppend)
+	if err != nil {
+		return nil, err
+	}
+	if err = s.header.match(exchange, symbol, interval); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
 }
 
-// Use an existing file with default path
-func DefaultStorage(symbol string) (*Storage, error) {
-	return defaultStorage(symbol, flagAppend)
+// Returns the default path of the data file of the dataset in the current directory
+func DefaultPath(exchange, symbol string, interval Interval) (string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(wd, DefaultDir(exchange), DefaultFileName(symbol, interval)), nil
 }
 
 // Create a new file from a path
-func NewFileStorage(path string) (*Storage, error) {
+func NewFileStorage(path string, h Header) (*Storage, error) {
 	dir, file := filepath.Split(path)
-	return fileStorage(dir, file, flagNew)
+	return fileStorage(dir, file, &h, flagNew)
 }
 
-// Use an existin file from a path
+// Use an existin file from a path, the header is read from the file
 func FileStorage(path string) (*Storage, error) {
 	dir, file := filepath.Split(path)
-	return fileStorage(dir, file, flagAppend)
+	return fileStorage(dir, file, nil, flagAppend)
+}
+
+// Returns the default file name for a symbol and an interval like BTCUSDT-1m.bin
+func DefaultFileName(symbol string, interval Interval) string {
+	return symbol + interval.fileSuffix() + DefaultExt
+}
+
+// Returns the default directory of the exchange data relative to the current one,
+// Binance data is in the root for compatibility, other exchanges have own subdirectory
+func DefaultDir(exchange string) string {
+	if exchange == "" || exchange == DefaultExchange {
+		return DefaultDataDir
+	}
+	return filepath.Join(DefaultDataDir, exchange)
 }
 
 // Create default dir and file in the current directory
-func defaultStorage(symbol string, flag int) (*Storage, error) {
-	wd, err := os.Getwd()
+func defaultStorage(exchange, symbol string, interval Interval, h *Header, flag int) (*Storage, error) {
+	path, err := DefaultPath(exchange, symbol, interval)
 	if err != nil {
 		return nil, err
 	}
-	dir := filepath.Join(wd, DefaultDataDir)
-	return fileStorage(dir, symbol+DefaultExt, flag)
+	dir, file := filepath.Split(path)
+	return fileStorage(dir, file, h, flag)
 }
 
-// Creates a directory if necessary and open or create a file depending on the flag
-func fileStorage(dir, file string, flag int) (*Storage, error) {
+// Creates a directory if necessary and open or create a file depending on the flag,
+// a new file gets the header h, the header of an existing one is read and validated
+func fileStorage(dir, file string, h *Header, flag int) (*Storage, error) {
 	if len(file) == 0 {
 		return nil, errors.New("file name is required")
 	}
+	// do not truncate an existing file for an invalid header
+	if flag&os.O_TRUNC != 0 {
+		if err := h.validate(); err != nil {
+			return nil, err
+		}
+	}
 	if dir != "" {
 		if err := os.MkdirAll(dir, DefaultDirPerm); err != nil {
 			return nil, err
@@ -80,31 +156,119 @@ func fileStorage(dir, file string, flag int) (*Storage, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Storage{fd: fd}, nil
+	if flag&os.O_TRUNC != 0 {
+		err = writeHeader(fd, h)
+	} else {
+		h, err = readHeader(fd)
+	}
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+	size := h.Format.RecordSize()
+	return &Storage{
+		fd:     fd,
+		header: h,
+		format: h.Format,
+		size:   size,
+		offset: HeaderByteSize,
+	}, nil
+}
+
+// Truncate a partial record at the end of the file left by an interrupted write,
+// the complete candles before it are kept. Returns the number of bytes removed.
+// It must be called before appending to an existing file and only by its writer,
+// a partial record of a writer in progress would be lost, readers ignore it
+func (s *Storage) Recover() (int64, error) {
+	size, err := s.SizeBytes()
+	if err != nil {
+		return 0, err
+	}
+	torn := (size - s.offset) % int64(s.size)
+	if torn == 0 {
+		s.aligned = true
+		return 0, nil
+	}
+	if err = s.fd.Truncate(size - torn); err != nil {
+		return 0, errorWrap("truncate a partial candle", err)
+	}
+	if err = s.fd.Sync(); err != nil {
+		return 0, err
+	}
+	s.aligned = true
+	return torn, nil
 }
 
+// Set when the saved candles are committed to the disk, SyncOnClose is the default
+func (s *Storage) SetDurability(d Durability) {
+	s.sync = d
+}
+
+// Returns the format of the candles in the data file
+func (s *Storage) Format() Format {
+	return s.format
+}
+
+// Returns a copy of the data file header
+func (s *Storage) Header() Header {
+	return *s.header
+}
+
+// Commit the written candles to the disk and close the file
 func (s *Storage) Close() error {
-	return s.fd.Close()
+	var err error
+	if s.dirty {
+		err = s.Sync()
+	}
+	if cerr := s.fd.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Commit the written candles to the disk
+func (s *Storage) Sync() error {
+	if err := s.fd.Sync(); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
 }
 
-// Convert candles to bytes and write them to a file to the data file
+// Convert candles to bytes and write them to the data file with a write call
+// per saveBatch candles, they are synced before the return with SyncPerBatch.
+// A partial candle at the end of an existing file is removed before the first write
 func (s *Storage) Save(b []Candle) error {
 	if len(b) == 0 {
 		return nil
 	}
-	bs := s.writeBuf[:]
-	for i := range b {
-		binary.LittleEndian.PutUint32(bs[:4], math.Float32bits(b[i].HPrice))
-		binary.LittleEndian.PutUint32(bs[4:8], math.Float32bits(b[i].LPrice))
-		binary.LittleEndian.PutUint32(bs[8:12], math.Float32bits(b[i].CPrice))
-		binary.LittleEndian.PutUint32(bs[12:16], math.Float32bits(b[i].Volume))
-		binary.LittleEndian.PutUint32(bs[16:20], b[i].CTime)
-		// write one candle
+	if !s.aligned {
+		if _, err := s.Recover(); err != nil {
+			return err
+		}
+	}
+	s.dirty = true
+	for len(b) > 0 {
+		n := min(len(b), saveBatch)
+		nb := n * s.size
+		if nb > cap(s.writeBuf) {
+			s.writeBuf = make([]byte, nb)
+		}
+		bs := s.writeBuf[:nb]
+		for i := 0; i < n; i++ {
+			off := i * s.size
+			s.format.encode(&b[i], bs[off:off+s.size])
+		}
+		// a partial write leaves a torn candle which is removed at the next open
 		if _, err := s.fd.Write(bs); err != nil {
 			return err
 		}
+		b = b[n:]
 	}
 
+	if s.sync == SyncPerBatch {
+		return s.Sync()
+	}
 	return nil
 }
 
@@ -117,64 +281,110 @@ func (s *Storage) SizeBytes() (int64, error) {
 	return fi.Size(), nil
 }
 
-// Returns length in candles for the current data file
-func (s *Storage) SizeCandles() (int64, error) {
+// Returns length in bytes of the complete candles without the header,
+// a partial one at the end is being written or is torn
+func (s *Storage) dataSize() (int64, error) {
 	size, err := s.SizeBytes()
 	if err != nil {
 		return 0, err
 	}
-	if size%CandleByteSize != 0 {
-		return 0, errors.New("get size from a corrupted candles file")
+	size -= s.offset
+	return size - size%int64(s.size), nil
+}
+
+// Returns length in candles for the current data file
+func (s *Storage) SizeCandles() (int64, error) {
+	size, err := s.dataSize()
+	if err != nil {
+		return 0, err
 	}
-	return int64(size / CandleByteSize), nil
+	return size / int64(s.size), nil
 }
 
+// Read all candles of the data file, the read position is not changed
 func (s *Storage) ReadAll() ([]Candle, error) {
-	size, err := s.SizeBytes()
+	size, err := s.dataSize()
 	if err != nil {
 		return nil, err
 	}
-	size++ // one byte for final read at EOF
-	bs := make([]byte, 0, size)
-	var n int64
-	for {
-		nr, err := s.fd.Read(bs[len(bs):cap(bs)])
-		n += int64(nr)
-		bs = bs[:len(bs)+nr]
-		if err != nil {
-			if err == io.EOF {
-				err = nil
-			}
-			break
-		}
-		if len(bs) >= cap(bs) {
-			d := append(bs[:cap(bs)], 0)
-			bs = d[:len(bs)]
-		}
+	bs := make([]byte, size)
+	n, err := s.fd.ReadAt(bs, s.offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	// cast n to candles len, the file may have grown since the size
+	cs := make([]Candle, n/s.size)
+	// bytes to candles
+	s.bs2cs(bs, cs, len(cs))
+	return cs, nil
+}
+
+// Move the read position to the first candle closed later than t (milli seconds),
+// the candles are expected to be sorted. Returns the number of candles before it,
+// it is the size in candles when all of them are closed not later than t
+func (s *Storage) SeekTime(t int64) (int64, error) {
+	i, err := s.searchTime(t)
+	if err != nil {
+		return 0, err
 	}
+	s.readPos = i * int64(s.size)
+	return i, nil
+}
 
+// Read the candles closed later than from and not later than to (milli seconds),
+// the read position is not changed
+func (s *Storage) ReadRange(from, to int64) ([]Candle, error) {
+	i, err := s.searchTime(from)
+	if err != nil {
+		return nil, err
+	}
+	j, err := s.searchTime(to)
+	if err != nil {
+		return nil, err
+	}
+	if j <= i {
+		return nil, nil
+	}
+	cs := make([]Candle, j-i)
+	n, err := s.readAt(cs, i*int64(s.size))
 	if err != nil && err != io.EOF {
 		return nil, err
 	}
-	if n%CandleByteSize != 0 {
-		return nil, errors.New("read all from a corrupted candles file")
+	return cs[:n], nil
+}
+
+// Returns the number of candles closed not later than t with a binary search
+// reading only the close times
+func (s *Storage) searchTime(t int64) (int64, error) {
+	n, err := s.SizeCandles()
+	if err != nil {
+		return 0, err
 	}
-	// cast n to candles len
-	n = n / CandleByteSize
-	cs := make([]Candle, n)
-	// bytes to candles
-	bs2cs(bs, cs, len(cs))
-	return cs, nil
+	lo, hi := int64(0), n
+	for lo < hi {
+		mid := int64(uint64(lo+hi) >> 1)
+		ct, err := s.readCandleCloseTime(mid * int64(s.size))
+		if err != nil {
+			return 0, err
+		}
+		if ct <= t {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, nil
 }
 
 // Read bytes from the end of the current data file and convert them to candles
-// Returns the number of candle read and the error
+// Returns the number of candle read and the error. The read position is shared
+// with Read, a Cursor has its own one
 func (s *Storage) ReadBack(cs []Candle) (int, error) {
-	size, err := s.SizeBytes()
+	size, err := s.dataSize()
 	if err != nil {
 		return 0, err
 	}
-	pos := size - int64(len(cs)*CandleByteSize) - s.readPos
+	pos := size - int64(len(cs)*s.size) - s.readPos
 	if pos < 0 {
 		pos = 0
 	}
@@ -188,27 +398,36 @@ func (s *Storage) Read(cs []Candle) (int, error) {
 }
 
 func (s *Storage) read(cs []Candle, pos int64) (int, error) {
-	nb := len(cs) * CandleByteSize
+	n, err := s.readAt(cs, pos)
+	s.readPos += int64(n * s.size)
+	return n, err
+}
+
+// Read candles at the byte position pos after the header,
+// the read position is not changed
+func (s *Storage) readAt(cs []Candle, pos int64) (int, error) {
+	return s.readAtBuf(cs, pos, &s.readBuf)
+}
+
+// Read candles at the byte position pos after the header with the bytes
+// of buf, it grows when it is too short
+func (s *Storage) readAtBuf(cs []Candle, pos int64, buf *[]byte) (int, error) {
+	nb := len(cs) * s.size
 	// nil slice also has cap 0
-	if nb > cap(s.readBuf) {
-		s.readBuf = make([]byte, nb)
+	if nb > cap(*buf) {
+		*buf = make([]byte, nb)
 	}
 
 	// cut or stretch after previous use
-	bs := s.readBuf[:nb]
-	n, err := s.fd.ReadAt(bs, pos)
+	bs := (*buf)[:nb]
+	n, err := s.fd.ReadAt(bs, s.offset+pos)
 	if err != nil && err != io.EOF {
 		return 0, err
 	}
-	if n%CandleByteSize != 0 {
-		return 0, errors.New("read from a corrupted candles file")
-	}
-	s.readPos += int64(n)
-
-	// cast n to candles len
-	n = int(n / CandleByteSize)
+	// cast n to candles len, a partial candle at the end is not read
+	n = n / s.size
 	// bytes to candles
-	bs2cs(bs, cs, n)
+	s.bs2cs(bs, cs, n)
 
 	// err may be io.EOF
 	return n, err
@@ -216,24 +435,26 @@ func (s *Storage) read(cs []Candle, pos int64) (int, error) {
 
 // return timestamp as milli seconds of the first candle
 func (s *Storage) FirstCandleCloseTime() (int64, error) {
-	size, err := s.SizeBytes()
-	if size < CandleByteSize || err != nil {
+	size, err := s.dataSize()
+	if size < int64(s.size) || err != nil {
 		return 0, err
 	}
-	return s.readCandleCloseTime(int64(CandleByteSize))
+	return s.readCandleCloseTime(0)
 }
 
 // return timestamp as milli seconds of the last candle
 func (s *Storage) LastCandleCloseTime() (int64, error) {
-	size, err := s.SizeBytes()
-	if size < CandleByteSize || err != nil {
+	size, err := s.dataSize()
+	if size < int64(s.size) || err != nil {
 		return 0, err
 	}
-	return s.readCandleCloseTime(size)
+	return s.readCandleCloseTime(size - int64(s.size))
 }
 
+// offset is the start of a candle record after the header,
+// the close time follows the open time
 func (s *Storage) readCandleCloseTime(offset int64) (int64, error) {
-	at := offset - 4 // 4 bytes for int32
+	at := s.offset + offset + 4 // 4 bytes for the open time
 	b := make([]byte, 4)
 	n, err := s.fd.ReadAt(b, at)
 	if err != nil && err != io.EOF {
@@ -251,14 +472,9 @@ func SecToMilli(t uint32) int64 {
 	return int64(t) * 1000
 }
 
-func bs2cs(bs []byte, cs []Candle, n int) {
-	var off int
+func (s *Storage) bs2cs(bs []byte, cs []Candle, n int) {
 	for i := 0; i < n; i++ {
-		off = i * CandleByteSize
-		cs[i].HPrice = math.Float32frombits(binary.LittleEndian.Uint32(bs[off : 4+off]))
-		cs[i].LPrice = math.Float32frombits(binary.LittleEndian.Uint32(bs[4+off : 8+off]))
-		cs[i].CPrice = math.Float32frombits(binary.LittleEndian.Uint32(bs[8+off : 12+off]))
-		cs[i].Volume = math.Float32frombits(binary.LittleEndian.Uint32(bs[12+off : 16+off]))
-		cs[i].CTime = binary.LittleEndian.Uint32(bs[16+off : 20+off])
+		off := i * s.size
+		s.format.decode(bs[off:off+s.size], &cs[i])
 	}
 }
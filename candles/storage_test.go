@@ -1,22 +1,30 @@
 package candles
 
 import (
+	"bytes"
+	"errors"
 	"io"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
 const (
-	testFile = "/tmp/test-candles.bin"
+	testFile     = "/tmp/test-candles.bin"
+	testExtFile  = "/tmp/test-candles-ext.bin"
+	testF64File  = "/tmp/test-candles-f64.bin"
+	testRawFile  = "/tmp/test-candles-raw.bin"
+	testSeekFile = "/tmp/test-candles-seek.bin"
 )
 
 var (
-	a Candle = Candle{1, 1, 1, 1, 1}
-	b Candle = Candle{2, 2, 2, 2, 2}
-	c Candle = Candle{3, 3, 3, 3, 3}
+	a Candle = Candle{1, 1, 1, 1, 1, 0, 1, 0, 0, 0, 0}
+	b Candle = Candle{2, 2, 2, 2, 2, 1, 2, 0, 0, 0, 0}
+	c Candle = Candle{3, 3, 3, 3, 3, 2, 3, 0, 0, 0, 0}
 )
 
 func TestStorageSave(t *testing.T) {
-	stg, err := NewFileStorage(testFile)
+	stg, err := NewFileStorage(testFile, Header{})
 	if err != nil {
 		t.Errorf("create new storage: %s", err.Error())
 	}
@@ -153,7 +161,7 @@ func TestStorageReadAll(t *testing.T) {
 	if err != nil {
 		t.Errorf("read all candles file: %s", err.Error())
 	}
-    wantLen := 3
+	wantLen := 3
 	if len(cs) != wantLen {
 		t.Errorf("reading candles: want len %d, got %d", wantLen, len(cs))
 	}
@@ -164,3 +172,321 @@ func TestStorageReadAll(t *testing.T) {
 		t.Errorf("candles not equal: want %#v, got %#v", c, cs[2])
 	}
 }
+
+func TestStorageSeekTime(t *testing.T) {
+	stg, err := NewFileStorage(testSeekFile, Header{Interval: Interval1s})
+	if err != nil {
+		t.Fatalf("create new storage: %s", err.Error())
+	}
+	defer stg.Close()
+	cs := make([]Candle, 100)
+	for i := range cs {
+		cs[i] = testCandle(uint32(1000 + 2*i))
+	}
+	if err = stg.Save(cs); err != nil {
+		t.Fatalf("save candles: %s", err.Error())
+	}
+
+	// at a close time and between two of them
+	for at, want := range map[int64]int64{1100000: 51, 1101500: 51} {
+		i, err := stg.SeekTime(at)
+		if err != nil {
+			t.Fatalf("seek time: %s", err.Error())
+		}
+		if i != want {
+			t.Errorf("seek time %d: want %d, got %d", at, want, i)
+		}
+	}
+	got := make([]Candle, 1)
+	if _, err = stg.Read(got); err != nil {
+		t.Errorf("read after seek: %s", err.Error())
+	}
+	if got[0].CTime != 1102 {
+		t.Errorf("read after seek: want close time 1102, got %d", got[0].CTime)
+	}
+	if i, _ := stg.SeekTime(0); i != 0 {
+		t.Errorf("seek before the first: want 0, got %d", i)
+	}
+	if i, _ := stg.SeekTime(2000000); i != 100 {
+		t.Errorf("seek after the last: want 100, got %d", i)
+	}
+
+	rng, err := stg.ReadRange(1010000, 1020000)
+	if err != nil {
+		t.Fatalf("read range: %s", err.Error())
+	}
+	if len(rng) != 5 || rng[0].CTime != 1012 || rng[4].CTime != 1020 {
+		t.Errorf("read range: want 5 candles of 1012-1020, got %#v", rng)
+	}
+	if rng, _ = stg.ReadRange(1020000, 1010000); len(rng) != 0 {
+		t.Errorf("empty range: want no candles, got %d", len(rng))
+	}
+}
+
+func TestStorageTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "BTCUSDT-1s.bin")
+	stg, err := NewFileStorage(path, Header{})
+	if err != nil {
+		t.Fatalf("create new storage: %s", err.Error())
+	}
+	stg.SetDurability(SyncPerBatch)
+	if err = stg.Save([]Candle{a, b}); err != nil {
+		t.Fatalf("save candles: %s", err.Error())
+	}
+	stg.Close()
+
+	// a write killed in the middle of the third candle
+	fd, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("open file: %s", err.Error())
+	}
+	fd.Write([]byte{1, 2, 3, 4, 5, 6, 7})
+	fd.Close()
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat file: %s", err.Error())
+	}
+	torn := fi.Size()
+
+	// a reader ignores the partial candle and leaves the file as it is
+	stg, err = FileStorage(path)
+	if err != nil {
+		t.Fatalf("open torn storage: %s", err.Error())
+	}
+	n, err := stg.SizeCandles()
+	if err != nil || n != 2 {
+		t.Fatalf("size of torn storage: want 2, got %d %v", n, err)
+	}
+	last, err := stg.LastCandleCloseTime()
+	if err != nil || last != SecToMilli(b.CTime) {
+		t.Errorf("last close time of torn storage: want %d, got %d %v", SecToMilli(b.CTime), last, err)
+	}
+	got, err := stg.ReadAll()
+	if err != nil || len(got) != 2 {
+		t.Errorf("read torn storage: want 2 candles, got %d %v", len(got), err)
+	}
+	stg.Close()
+	if fi, err = os.Stat(path); err != nil || fi.Size() != torn {
+		t.Fatalf("size after read: want %d bytes, got %d %v", torn, fi.Size(), err)
+	}
+
+	stg, err = FileStorage(path)
+	if err != nil {
+		t.Fatalf("open torn storage: %s", err.Error())
+	}
+	defer stg.Close()
+	removed, err := stg.Recover()
+	if err != nil || removed != 7 {
+		t.Errorf("recover: want 7 bytes removed, got %d %v", removed, err)
+	}
+	// the next save continues after the last complete candle
+	if err = stg.Save([]Candle{c}); err != nil {
+		t.Fatalf("save after recovery: %s", err.Error())
+	}
+	got, err = stg.ReadAll()
+	if err != nil || len(got) != 3 || got[2] != c {
+		t.Errorf("read after recovery: want %v, got %v %v", []Candle{a, b, c}, got, err)
+	}
+}
+
+func TestStorageSaveTorn(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "BTCUSDT-1s.bin")
+	stg, err := NewFileStorage(path, Header{})
+	if err != nil {
+		t.Fatalf("create new storage: %s", err.Error())
+	}
+	if err = stg.Save([]Candle{a, b}); err != nil {
+		t.Fatalf("save candles: %s", err.Error())
+	}
+	stg.Close()
+
+	fd, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("open file: %s", err.Error())
+	}
+	fd.Write([]byte{1, 2, 3, 4, 5, 6, 7})
+	fd.Close()
+
+	// a library writer which does not call Recover
+	stg, err = FileStorage(path)
+	if err != nil {
+		t.Fatalf("open torn storage: %s", err.Error())
+	}
+	defer stg.Close()
+	if err = stg.Save([]Candle{c}); err != nil {
+		t.Fatalf("save to torn storage: %s", err.Error())
+	}
+	got, err := stg.ReadAll()
+	if err != nil || len(got) != 3 || got[0] != a || got[1] != b || got[2] != c {
+		t.Errorf("read after save: want %v, got %v %v", []Candle{a, b, c}, got, err)
+	}
+}
+
+func TestStorageSaveBatches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "BTCUSDT-1s.bin")
+	h := Header{Format: Format{Layout: LayoutExtended}}
+	stg, err := NewFileStorage(path, h)
+	if err != nil {
+		t.Fatalf("create new storage: %s", err.Error())
+	}
+	defer stg.Close()
+	// more than one write call
+	cs := make([]Candle, saveBatch+10)
+	for i := range cs {
+		cs[i] = testCandle(uint32(1000 + i))
+		cs[i].Trades = uint32(i)
+	}
+	if err = stg.Save(cs); err != nil {
+		t.Fatalf("save candles: %s", err.Error())
+	}
+
+	// the same bytes as a record per candle after the header
+	var want bytes.Buffer
+	if err = writeHeader(&want, stg.header); err != nil {
+		t.Fatalf("write header: %s", err.Error())
+	}
+	rec := make([]byte, stg.size)
+	for i := range cs {
+		stg.format.encode(&cs[i], rec)
+		want.Write(rec)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read file: %s", err.Error())
+	}
+	if !bytes.Equal(got, want.Bytes()) {
+		t.Errorf("saved bytes: want %d bytes of records, got %d different bytes", want.Len(), len(got))
+	}
+}
+
+func TestStorageExtendedLayout(t *testing.T) {
+	f := Format{Layout: LayoutExtended}
+	stg, err := NewFileStorage(testExtFile, Header{Format: f})
+	if err != nil {
+		t.Fatalf("create new storage: %s", err.Error())
+	}
+
+	x := Candle{1, 2, 0.5, 1.5, 10, 60, 61, 15, 7, 4, 6}
+	if err = stg.Save([]Candle{a, x}); err != nil {
+		t.Errorf("save candles: %s", err.Error())
+	}
+	stg.Close()
+
+	stg, err = FileStorage(testExtFile)
+	if err != nil {
+		t.Fatalf("open existing storage: %s", err.Error())
+	}
+	defer stg.Close()
+
+	n, err := stg.SizeCandles()
+	if err != nil {
+		t.Errorf("get size candles: %s", err.Error())
+	}
+	if n != 2 {
+		t.Errorf("size candles: want 2, got %d", n)
+	}
+
+	cs, err := stg.ReadAll()
+	if err != nil {
+		t.Errorf("read all candles file: %s", err.Error())
+	}
+	if len(cs) != 2 || cs[0] != a || cs[1] != x {
+		t.Errorf("candles not equal: want %#v, got %#v", []Candle{a, x}, cs)
+	}
+
+	cTime, err := stg.LastCandleCloseTime()
+	if err != nil {
+		t.Errorf("get last candle close time: %s", err.Error())
+	}
+	if want := SecToMilli(x.CTime); cTime != want {
+		t.Errorf("candle close time: want %d, got %d", want, cTime)
+	}
+}
+
+func TestStorageFloat64Encoding(t *testing.T) {
+	f := Format{Layout: LayoutExtended, Encoding: EncodingFloat64}
+	stg, err := NewFileStorage(testF64File, Header{Format: f})
+	if err != nil {
+		t.Fatalf("create new storage: %s", err.Error())
+	}
+
+	// float32 can't hold the cents of such price
+	x := Candle{61234.56, 61234.57, 61234.01, 61234.02, 0.00012345, 60, 61, 7.55912345, 3, 0.0001, 6.12}
+	if err = stg.Save([]Candle{x}); err != nil {
+		t.Errorf("save candles: %s", err.Error())
+	}
+	stg.Close()
+
+	stg, err = FileStorage(testF64File)
+	if err != nil {
+		t.Fatalf("open existing storage: %s", err.Error())
+	}
+	defer stg.Close()
+
+	if stg.Format() != f {
+		t.Errorf("format from header: want %#v, got %#v", f, stg.Format())
+	}
+	cs, err := stg.ReadAll()
+	if err != nil {
+		t.Errorf("read all candles file: %s", err.Error())
+	}
+	if len(cs) != 1 || cs[0] != x {
+		t.Errorf("candles not equal: want %#v, got %#v", x, cs)
+	}
+}
+
+func TestStorageUnknownFormat(t *testing.T) {
+	// a file without header
+	if err := os.WriteFile(testRawFile, make([]byte, Format{}.RecordSize()), DefaultFilePerm); err != nil {
+		t.Fatalf("write raw file: %s", err.Error())
+	}
+	_, err := FileStorage(testRawFile)
+	if !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("open raw file: want error '%s', got '%v'", ErrUnknownFormat, err)
+	}
+}
+
+// Save a batch of the API size, the records are written with one call
+func BenchmarkStorageSave(b *testing.B) {
+	stg, err := NewFileStorage(filepath.Join(b.TempDir(), "BTCUSDT-1s.bin"), Header{})
+	if err != nil {
+		b.Fatalf("create new storage: %s", err.Error())
+	}
+	defer stg.Close()
+	cs := make([]Candle, 1000)
+	for i := range cs {
+		cs[i] = testCandle(uint32(1000 + i))
+	}
+	b.SetBytes(int64(len(cs) * stg.size))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err = stg.Save(cs); err != nil {
+			b.Fatalf("save candles: %s", err.Error())
+		}
+	}
+}
+
+// The same batch written with a call per record for the comparison
+func BenchmarkStorageSavePerCandle(b *testing.B) {
+	stg, err := NewFileStorage(filepath.Join(b.TempDir(), "BTCUSDT-1s.bin"), Header{})
+	if err != nil {
+		b.Fatalf("create new storage: %s", err.Error())
+	}
+	defer stg.Close()
+	cs := make([]Candle, 1000)
+	for i := range cs {
+		cs[i] = testCandle(uint32(1000 + i))
+	}
+	rec := make([]byte, stg.size)
+	b.SetBytes(int64(len(cs) * stg.size))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range cs {
+			stg.format.encode(&cs[j], rec)
+			if _, err = stg.fd.Write(rec); err != nil {
+				b.Fatalf("write candle: %s", err.Error())
+			}
+		}
+	}
+}
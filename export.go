@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/k0l1br1/loader/candles"
+)
+
+const exportUsage = `usage: loader export -s <symbol> [options]
+    -s, --symbol        The pair of the data
+    -i, --interval      The kline interval, or a derived one (default 1s)
+    --exchange          The exchange of the data (default binance)
+    -o, --output        The file to write (default stdout)
+    --format            csv, jsonl or parquet (default is the output extension
+                        or csv)
+    --columns           The fields to write separated by commas, of open_time
+                        close_time open high low close volume quote_volume
+                        trades taker_buy_volume taker_buy_quote_volume
+                        (default all stored, close_time is the next open)
+    --time-format       s or ms unix time or rfc3339 (default ms), parquet
+                        has timestamp columns
+    --row-group         The rows of a parquet row group (default 1048576)
+    --from              Date (UTC) of the first candle open
+    --to                Date (UTC) not later than which the last candle closes
+                        (format like 2024-02-19 03:37:05)
+`
+
+const (
+	exportCSV     = "csv"
+	exportJSONL   = "jsonl"
+	exportParquet = "parquet"
+)
+
+var (
+	errInvalidExportFormat = errors.New("format must be csv, jsonl or parquet")
+	errInvalidRowGroup     = errors.New("row group must be a positive number")
+)
+
+type exportOptions struct {
+	Exchange string
+	Symbol   string
+	Interval candles.Interval
+	Output   string
+	Format   string
+	Export   candles.ExportOptions
+}
+
+func parseExportOptions(args []string) (*exportOptions, error) {
+	if len(args) < 2 {
+		printUsage(exportUsage)
+	}
+	opts := &exportOptions{Exchange: candles.DefaultExchange, Interval: candles.DefaultInterval}
+
+	// args[0] is the command name
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		j := i + 1
+		hasValue := len(args) > j && !strings.HasPrefix(args[j], "-")
+		switch arg {
+		case "-h", "--help":
+			printUsage(exportUsage)
+		case "-s", "--symbol":
+			if hasValue {
+				opts.Symbol = strings.ToUpper(args[j])
+				i++
+			}
+		case "-i", "--interval":
+			if hasValue {
+				interval, err := candles.ParseDerivedInterval(args[j])
+				if err != nil {
+					return nil, errorWrap("parse options interval", err)
+				}
+				opts.Interval = interval
+				i++
+			}
+		case "--exchange":
+			if hasValue {
+				exchange, err := parseExchange(args[j])
+				if err != nil {
+					return nil, errorWrap("parse options exchange", err)
+				}
+				opts.Exchange = exchange
+				i++
+			}
+		case "-o", "--output":
+			if hasValue {
+				opts.Output = args[j]
+				i++
+			}
+		case "--format":
+			if hasValue {
+				opts.Format = strings.ToLower(args[j])
+				i++
+			}
+		case "--columns":
+			if hasValue {
+				opts.Export.Columns = strings.Split(args[j], ",")
+				i++
+			}
+		case "--time-format":
+			if hasValue {
+				opts.Export.TimeFormat = strings.ToLower(args[j])
+				i++
+			}
+		case "--row-group":
+			if hasValue {
+				n, err := strconv.Atoi(args[j])
+				if err != nil || n <= 0 {
+					return nil, errorWrap("parse options row group", errInvalidRowGroup)
+				}
+				opts.Export.RowGroupRows = n
+				i++
+			}
+		case "--from":
+			if hasValue {
+				t, err := convertTimeToTimestamp(args[j])
+				if err != nil {
+					return nil, errorWrap("parse options from", err)
+				}
+				opts.Export.From = t
+				i++
+			}
+		case "--to":
+			if hasValue {
+				t, err := convertTimeToTimestamp(args[j])
+				if err != nil {
+					return nil, errorWrap("parse options to", err)
+				}
+				opts.Export.To = t
+				i++
+			}
+		}
+	}
+
+	if opts.Symbol == "" {
+		return nil, errReqSymbol
+	}
+	if opts.Format == "" {
+		opts.Format = strings.TrimPrefix(filepath.Ext(opts.Output), ".")
+		if opts.Format != exportJSONL && opts.Format != exportParquet {
+			opts.Format = exportCSV
+		}
+	}
+	switch opts.Format {
+	case exportCSV, exportJSONL, exportParquet:
+	default:
+		return nil, errorWrap("parse options format", errInvalidExportFormat)
+	}
+	if opts.Export.To != 0 && opts.Export.To <= opts.Export.From {
+		return nil, errEndBeforeStart
+	}
+	return opts, nil
+}
+
+// Returns the writer of the format
+func newCandleWriter(format string, w io.Writer, h candles.Header, opts *candles.ExportOptions) (candles.CandleWriter, error) {
+	switch format {
+	case exportJSONL:
+		return candles.NewJSONLWriter(w, h, opts)
+	case exportParquet:
+		return candles.NewParquetWriter(w, h, opts)
+	}
+	return candles.NewCSVWriter(w, h, opts)
+}
+
+func runExport(args []string) int {
+	opts, err := parseExportOptions(args)
+	if err != nil {
+		errorPrint(err)
+		if err == errReqSymbol {
+			return exitOk
+		}
+		return exitError
+	}
+
+	stg, err := candles.DefaultStorage(opts.Exchange, opts.Symbol, opts.Interval)
+	if err != nil {
+		errorPrint(errorWrap("open storage", err))
+		return exitError
+	}
+	defer stg.Close()
+
+	// an invalid column does not leave an empty output file
+	if err = candles.ValidateExportOptions(stg.Header(), &opts.Export); err != nil {
+		errorPrint(errorWrap("export", err))
+		return exitError
+	}
+	out := os.Stdout
+	if opts.Output != "" {
+		out, err = os.Create(opts.Output)
+		if err != nil {
+			errorPrint(errorWrap("create output", err))
+			return exitError
+		}
+		defer out.Close()
+	}
+	w, err := newCandleWriter(opts.Format, out, stg.Header(), &opts.Export)
+	if err != nil {
+		errorPrint(errorWrap("export", err))
+		return exitError
+	}
+	n, err := candles.Export(stg, w, opts.Export.From, opts.Export.To)
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		errorPrint(errorWrap("export", err))
+		return exitError
+	}
+	if opts.Output != "" {
+		if err = out.Close(); err != nil {
+			errorPrint(errorWrap("close output", err))
+			return exitError
+		}
+		fmt.Printf("Exported %d %s %s candles to %s\n", n, opts.Symbol, opts.Interval, opts.Output)
+	}
+	return exitOk
+}
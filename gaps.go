@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/k0l1br1/loader/candles"
+)
+
+const gapsUsage = `usage: loader gaps -s <symbol> [options]
+    -s, --symbol        The pair of the data to check
+    --exchange          The exchange of the data (default binance)
+    -i, --interval      The kline interval (default 1s)
+    -r, --repair        Download the missing candles and rebuild the data file
+`
+
+type gapsOptions struct {
+	Repair   bool
+	Exchange string
+	Symbol   string
+	Interval candles.Interval
+}
+
+func parseGapsOptions(args []string) (*gapsOptions, error) {
+	if len(args) < 2 {
+		printUsage(gapsUsage)
+	}
+	opts := &gapsOptions{Exchange: candles.DefaultExchange, Interval: candles.DefaultInterval}
+
+	// args[0] is the command name
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		j := i + 1
+		hasValue := len(args) > j && !strings.HasPrefix(args[j], "-")
+		switch arg {
+		case "-h", "--help":
+			printUsage(gapsUsage)
+		case "-s", "--symbol":
+			if hasValue {
+				opts.Symbol = strings.ToUpper(args[j])
+				i++
+			}
+		case "--exchange":
+			if hasValue {
+				exchange, err := parseExchange(args[j])
+				if err != nil {
+					return nil, errorWrap("parse options exchange", err)
+				}
+				opts.Exchange = exchange
+				i++
+			}
+		case "-i", "--interval":
+			if hasValue {
+				interval, err := candles.ParseInterval(args[j])
+				if err != nil {
+					return nil, errorWrap("parse options interval", err)
+				}
+				opts.Interval = interval
+				i++
+			}
+		case "-r", "--repair":
+			opts.Repair = true
+		}
+	}
+
+	if opts.Symbol == "" {
+		return nil, errReqSymbol
+	}
+	return opts, nil
+}
+
+func gapPrint(g candles.Gap) {
+	layout := "2006-01-02 15:04:05"
+	from := time.UnixMilli(g.From).UTC().Format(layout)
+	to := time.UnixMilli(g.To).UTC().Format(layout)
+	fmt.Printf("%s - %s missing %d\n", from, to, g.Missing)
+}
+
+func findGaps(stg *candles.Storage, interval candles.Interval) ([]candles.Gap, bool) {
+	gaps, unordered, err := candles.FindGaps(stg, interval)
+	if err != nil {
+		errorPrint(errorWrap("find gaps", err))
+		return nil, false
+	}
+	var missing int64
+	for _, g := range gaps {
+		gapPrint(g)
+		missing += g.Missing
+	}
+	fmt.Printf("Found %d gaps, %d missing candles, %d unordered candles\n", len(gaps), missing, unordered)
+	return gaps, true
+}
+
+func runGaps(args []string) int {
+	opts, err := parseGapsOptions(args)
+	if err != nil {
+		errorPrint(err)
+		if err == errReqSymbol {
+			return exitOk
+		}
+		return exitError
+	}
+
+	// only the repair writes to the file
+	open := candles.DefaultStorage
+	if opts.Repair {
+		open = openAppendStorage
+	}
+	stg, err := open(opts.Exchange, opts.Symbol, opts.Interval)
+	if err != nil {
+		errorPrint(errorWrap("open storage", err))
+		return exitError
+	}
+	defer stg.Close()
+
+	gaps, ok := findGaps(stg, opts.Interval)
+	if !ok {
+		return exitError
+	}
+	if !opts.Repair || len(gaps) == 0 {
+		return exitOk
+	}
+
+	intChan := make(chan os.Signal, 1)
+	signal.Notify(intChan, os.Interrupt, syscall.SIGTERM)
+
+	lo := candles.DefaultLoadOptions(opts.Symbol)
+	lo.Exchange = opts.Exchange
+	lo.Interval = opts.Interval
+	n, err := candles.RepairGaps(stg, gaps, intChan, lo)
+	if err != nil {
+		if errors.Is(err, candles.ErrInterrupted) {
+			fmt.Println("Interrupted!")
+			return exitInterrupt
+		}
+		errorPrint(errorWrap("repair gaps", err))
+		return exitError
+	}
+	fmt.Printf("Downloaded %d candles, checking again\n", n)
+	if _, ok = findGaps(stg, opts.Interval); !ok {
+		return exitError
+	}
+	return exitOk
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/k0l1br1/loader/candles"
+)
+
+const importCSVUsage = `usage: loader import-csv -f <file> -s <symbol> [options]
+    -f, --file          The CSV file to import
+    -s, --symbol        The pair of the data
+    -i, --interval      The kline interval (default 1s)
+    --exchange          The exchange of the data (default binance)
+    -n, --is-new        The flag to init new instance for a symbol
+    -x, --extended      Store quote volume, number of trades and taker buy
+                        volumes along with OHLCV
+    --encoding          How to store prices and volumes of a new instance,
+                        float32 or float64 (default float32)
+    -d, --delimiter     The field delimiter, a character or tab (default ,)
+    --header            The first line has the column names
+    --columns           The columns of the candle fields by the index from 0
+                        or by the name in the header, the fields are open_time
+                        close_time open high low close volume quote_volume
+                        trades taker_buy_volume taker_buy_quote_volume
+                        (default open_time=0,open=1,high=2,low=3,close=4,volume=5)
+    --time-unit         The unit of numeric times, s ms us or ns (default ms)
+    --time-format       The layout of text times in UTC, like
+                        '2006-01-02 15:04:05', instead of numbers
+`
+
+var errInvalidDelimiter = errors.New("delimiter must be one character")
+
+type importCSVOptions struct {
+	IsNew    bool
+	Extended bool
+	File     string
+	Exchange string
+	Symbol   string
+	Interval candles.Interval
+	Encoding candles.Encoding
+	CSV      candles.CSVOptions
+}
+
+// Returns the delimiter rune, tab may be written as a word or as \t
+func parseDelimiter(s string) (rune, error) {
+	switch s {
+	case "tab", `\t`:
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 || size != len(s) || r == '"' || r == '\r' || r == '\n' {
+		return 0, errInvalidDelimiter
+	}
+	return r, nil
+}
+
+func parseImportCSVOptions(args []string) (*importCSVOptions, error) {
+	if len(args) < 2 {
+		printUsage(importCSVUsage)
+	}
+	opts := &importCSVOptions{Exchange: candles.DefaultExchange, Interval: candles.DefaultInterval}
+
+	// args[0] is the command name
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		j := i + 1
+		// a delimiter may be a dash
+		hasValue := len(args) > j && (!strings.HasPrefix(args[j], "-") || arg == "-d" || arg == "--delimiter")
+		switch arg {
+		case "-h", "--help":
+			printUsage(importCSVUsage)
+		case "-f", "--file":
+			if hasValue {
+				opts.File = args[j]
+				i++
+			}
+		case "-s", "--symbol":
+			if hasValue {
+				opts.Symbol = strings.ToUpper(args[j])
+				i++
+			}
+		case "-i", "--interval":
+			if hasValue {
+				interval, err := candles.ParseInterval(args[j])
+				if err != nil {
+					return nil, errorWrap("parse options interval", err)
+				}
+				opts.Interval = interval
+				i++
+			}
+		case "--exchange":
+			if hasValue {
+				exchange, err := parseExchange(args[j])
+				if err != nil {
+					return nil, errorWrap("parse options exchange", err)
+				}
+				opts.Exchange = exchange
+				i++
+			}
+		case "-n", "--is-new":
+			opts.IsNew = true
+		case "-x", "--extended":
+			opts.Extended = true
+		case "--encoding":
+			if hasValue {
+				e, err := candles.ParseEncoding(args[j])
+				if err != nil {
+					return nil, errorWrap("parse options encoding", err)
+				}
+				opts.Encoding = e
+				i++
+			}
+		case "-d", "--delimiter":
+			if hasValue {
+				r, err := parseDelimiter(args[j])
+				if err != nil {
+					return nil, errorWrap("parse options delimiter", err)
+				}
+				opts.CSV.Comma = r
+				i++
+			}
+		case "--header":
+			opts.CSV.Header = true
+		case "--columns":
+			if hasValue {
+				columns, err := candles.ParseCSVColumns(args[j])
+				if err != nil {
+					return nil, errorWrap("parse options columns", err)
+				}
+				opts.CSV.Columns = columns
+				i++
+			}
+		case "--time-unit":
+			if hasValue {
+				switch args[j] {
+				case candles.TimeUnitSeconds, candles.TimeUnitMilli, candles.TimeUnitMicro, candles.TimeUnitNano:
+				default:
+					return nil, errorWrap("parse options time unit", candles.ErrInvalidTimeUnit)
+				}
+				opts.CSV.TimeUnit = args[j]
+				i++
+			}
+		case "--time-format":
+			if hasValue {
+				opts.CSV.TimeLayout = args[j]
+				i++
+			}
+		}
+	}
+
+	if opts.File == "" {
+		return nil, errReqFile
+	}
+	if opts.Symbol == "" {
+		return nil, errReqSymbol
+	}
+	return opts, nil
+}
+
+func runImportCSV(args []string) int {
+	opts, err := parseImportCSVOptions(args)
+	if err != nil {
+		errorPrint(err)
+		if err == errReqFile || err == errReqSymbol {
+			return exitOk
+		}
+		return exitError
+	}
+
+	fd, err := os.Open(opts.File)
+	if err != nil {
+		errorPrint(errorWrap("open csv file", err))
+		return exitError
+	}
+	defer fd.Close()
+
+	var stg *candles.Storage
+	if opts.IsNew {
+		format := candles.Format{Layout: candles.LayoutCompact, Encoding: opts.Encoding}
+		if opts.Extended {
+			format.Layout = candles.LayoutExtended
+		}
+		stg, err = candles.NewDefaultStorage(candles.Header{
+			Format:   format,
+			Symbol:   opts.Symbol,
+			Interval: opts.Interval,
+			Exchange: opts.Exchange,
+		})
+	} else {
+		stg, err = openAppendStorage(opts.Exchange, opts.Symbol, opts.Interval)
+	}
+	if err != nil {
+		errorPrint(errorWrap("open storage", err))
+		return exitError
+	}
+	defer stg.Close()
+
+	n, rejected, err := candles.ImportCSV(stg, fd, &opts.CSV)
+	for _, r := range rejected {
+		errorPrint(errorWrap(opts.File+":"+strconv.Itoa(r.Line), r.Err))
+	}
+	if err != nil {
+		errorPrint(errorWrap("import csv", err))
+		return exitError
+	}
+	if err = stg.Sync(); err != nil {
+		errorPrint(errorWrap("sync storage", err))
+		return exitError
+	}
+	total, err := stg.SizeCandles()
+	if err != nil {
+		errorPrint(errorWrap("get total candles", err))
+		return exitError
+	}
+	fmt.Printf("Imported %d %s %s candles, rejected %d rows, total candles %d\n",
+		n, opts.Symbol, opts.Interval, len(rejected), total)
+	return exitOk
+}
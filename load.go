@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/k0l1br1/loader/candles"
+)
+
+// The outcome of loading one symbol
+type loadResult struct {
+	Symbol string
+	Loaded int64
+	Total  int64
+	Err    error
+}
+
+// Load every symbol with a bounded number of workers sharing one rate limiter,
+// the results are in the order of the symbols
+func loadAll(opts *options) []*loadResult {
+	limit := opts.WeightLimit
+	if limit == 0 {
+		limit = candles.WeightLimit(opts.Exchange)
+	}
+	limiter := candles.NewRateLimiter(limit)
+	results := make([]*loadResult, len(opts.Symbols))
+	jobs := make(chan int)
+
+	workers := min(opts.Workers, len(opts.Symbols))
+	// after a signal the symbols which have not started are skipped
+	var interrupted atomic.Bool
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			// every channel gets its own copy of a signal
+			intChan := make(chan os.Signal, 1)
+			signal.Notify(intChan, os.Interrupt, syscall.SIGTERM)
+			defer signal.Stop(intChan)
+			for i := range jobs {
+				if interrupted.Load() {
+					results[i] = &loadResult{Symbol: opts.Symbols[i], Err: candles.ErrInterrupted}
+					continue
+				}
+				results[i] = loadSymbol(opts, opts.Symbols[i], limiter, intChan)
+				if errors.Is(results[i].Err, candles.ErrInterrupted) {
+					interrupted.Store(true)
+				}
+			}
+		}()
+	}
+	for i := range opts.Symbols {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+func loadSymbol(opts *options, symbol string, limiter *candles.RateLimiter, intChan chan os.Signal) *loadResult {
+	r := &loadResult{Symbol: symbol}
+
+	var stg *candles.Storage
+	var err error
+	if opts.IsNew {
+		format := candles.Format{Layout: candles.LayoutCompact, Encoding: opts.Encoding}
+		if opts.Extended {
+			format.Layout = candles.LayoutExtended
+		}
+		stg, err = candles.NewDefaultStorage(candles.Header{
+			Format:   format,
+			Symbol:   symbol,
+			Interval: opts.Interval,
+			Exchange: opts.Exchange,
+		})
+		if err != nil {
+			r.Err = errorWrap("init new storage", err)
+			return r
+		}
+	} else {
+		stg, err = openAppendStorage(opts.Exchange, symbol, opts.Interval)
+		if err != nil {
+			r.Err = errorWrap("open storage", err)
+			return r
+		}
+	}
+	defer func() {
+		// with SyncOnClose the final sync of the loaded candles fails here
+		if err := stg.Close(); err != nil && r.Err == nil {
+			r.Err = errorWrap("close storage", err)
+		}
+	}()
+	stg.SetDurability(opts.Durability)
+
+	t := opts.StartTimestamp
+	if !opts.IsNew {
+		t, err = stg.LastCandleCloseTime()
+		if err != nil {
+			r.Err = errorWrap("load last close time from storage", err)
+			return r
+		}
+	}
+
+	totalCandles1, err := stg.SizeCandles()
+	if err != nil {
+		r.Err = errorWrap("get total candles", err)
+		return r
+	}
+
+	// an interrupted load still reports what it saved
+	r.Err = candles.Load(t, stg, intChan, &candles.LoadOptions{
+		Exchange:  opts.Exchange,
+		Symbol:    symbol,
+		Interval:  opts.Interval,
+		Retry:     opts.Retry,
+		EndTime:   opts.EndTimestamp,
+		BaseURL:   opts.BaseURL,
+		TLSConfig: opts.tlsConfig(),
+		Limiter:   limiter,
+	})
+
+	totalCandles2, err := stg.SizeCandles()
+	if err != nil {
+		if r.Err == nil {
+			r.Err = errorWrap("get total candles", err)
+		}
+		return r
+	}
+	r.Loaded = totalCandles2 - totalCandles1
+	r.Total = totalCandles2
+	return r
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/k0l1br1/loader/candles"
+)
+
+func TestLoadAll(t *testing.T) {
+	// the storage is in the current directory
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("get working directory: %s", err.Error())
+	}
+	if err = os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("change directory: %s", err.Error())
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("symbol") == "FAILUSDT" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"code":-1121,"msg":"Invalid symbol."}`)
+			return
+		}
+		start, err := strconv.ParseInt(q.Get("startTime"), 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// 3 minutes, less than a batch
+		fmt.Fprint(w, "[")
+		for i := int64(0); i < 3; i++ {
+			if i > 0 {
+				fmt.Fprint(w, ",")
+			}
+			ot := start + i*60000
+			fmt.Fprintf(w, `[%d,"2","2","2","2","2",%d,"0",0,"0","0","0"]`, ot, ot+59999)
+		}
+		fmt.Fprint(w, "]")
+	}))
+	defer ts.Close()
+
+	// 2024-02-19 00:00:00
+	var start int64 = 1708300800000
+	opts := &options{
+		IsNew:          true,
+		Exchange:       candles.ExchangeBinance,
+		Symbols:        []string{"BTCUSDT", "FAILUSDT", "ETHUSDT"},
+		Workers:        2,
+		Interval:       candles.Interval1m,
+		StartTimestamp: start,
+		Retry:          candles.RetryPolicy{MaxRetries: 1, MinDelay: time.Millisecond, MaxDelay: time.Millisecond},
+		BaseURL:        ts.URL,
+		Durability:     candles.SyncOnClose,
+	}
+	results := loadAll(opts)
+	if len(results) != len(opts.Symbols) {
+		t.Fatalf("results: want %d, got %d", len(opts.Symbols), len(results))
+	}
+	for i, r := range results {
+		if r.Symbol != opts.Symbols[i] {
+			t.Errorf("result %d: want symbol %s, got %s", i, opts.Symbols[i], r.Symbol)
+		}
+	}
+	if results[1].Err == nil {
+		t.Errorf("result of FAILUSDT: want an error, got %+v", results[1])
+	}
+
+	// the failed symbol does not stop the others
+	for _, r := range []*loadResult{results[0], results[2]} {
+		if r.Err != nil || r.Loaded != 3 || r.Total != 3 {
+			t.Errorf("result of %s: want 3 candles loaded, got %d of %d %v", r.Symbol, r.Loaded, r.Total, r.Err)
+			continue
+		}
+		stg, err := candles.DefaultStorage(opts.Exchange, r.Symbol, opts.Interval)
+		if err != nil {
+			t.Fatalf("open storage of %s: %s", r.Symbol, err.Error())
+		}
+		last, err := stg.LastCandleCloseTime()
+		stg.Close()
+		if err != nil || last != start+3*60000 {
+			t.Errorf("last close time of %s: want %d, got %d %v", r.Symbol, start+3*60000, last, err)
+		}
+	}
+}
@@ -4,8 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
 	"github.com/k0l1br1/loader/candles"
@@ -35,6 +33,23 @@ func errorWrap(msg string, err error) error {
 }
 
 func run() int {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			return runMigrate(os.Args[1:])
+		case "gaps":
+			return runGaps(os.Args[1:])
+		case "import-archive":
+			return runImportArchive(os.Args[1:])
+		case "import-csv":
+			return runImportCSV(os.Args[1:])
+		case "export":
+			return runExport(os.Args[1:])
+		case "resample":
+			return runResample(os.Args[1:])
+		}
+	}
+
 	opts, err := parseOptions(os.Args)
 	if err != nil {
 		errorPrint(err)
@@ -46,77 +61,79 @@ func run() int {
 		}
 	}
 
-	var stg *candles.Storage
-	if opts.IsNew {
-		stg, err = candles.NewDefaultStorage(opts.Symbol)
-		if err != nil {
-			errorPrint(errorWrap("init new storage", err))
-			return exitError
-		}
-	} else {
-		stg, err = candles.DefaultStorage(opts.Symbol)
-		if err != nil {
-			errorPrint(errorWrap("open storage", err))
-			return exitError
-		}
+	if opts.ShowStart || opts.ShowEnd {
+		return showTimes(opts)
 	}
-	defer stg.Close()
 
-	if opts.ShowStart {
-		t, err := stg.FirstCandleCloseTime()
-		if err != nil {
-			errorPrint(errorWrap("read storage first close time", err))
-			return exitError
-		}
-		datePrint(t)
-		return exitOk
-	}
-	if opts.ShowEnd {
-		t, err := stg.LastCandleCloseTime()
-		if err != nil {
-			errorPrint(errorWrap("read storage last close time", err))
-			return exitError
+	results := loadAll(opts)
+	code := exitOk
+	for _, r := range results {
+		switch {
+		case r.Err == nil:
+			fmt.Printf("%s: loaded %d candles, total candles %d\n", r.Symbol, r.Loaded, r.Total)
+		case errors.Is(r.Err, candles.ErrInterrupted):
+			fmt.Printf("%s: interrupted, loaded %d candles, total candles %d\n", r.Symbol, r.Loaded, r.Total)
+			code = exitInterrupt
+		default:
+			errorPrint(errorWrap(r.Symbol, r.Err))
+			if code == exitOk {
+				code = exitError
+			}
 		}
-		datePrint(t)
-		return exitOk
 	}
-
-	t := opts.StartTimestamp
-	if !opts.IsNew {
-		t, err = stg.LastCandleCloseTime()
-		if err != nil {
-			errorPrint(errorWrap("load last close time from storage", err))
-			return exitError
-		}
+	if code == exitOk {
+		fmt.Println("All done!")
 	}
+	return code
+}
 
-	totalCandles1, err := stg.SizeCandles()
+// Open the dataset to append to it, a partial candle left at the end of the file
+// by an interrupted write is removed with a warning
+func openAppendStorage(exchange, symbol string, interval candles.Interval) (*candles.Storage, error) {
+	stg, err := candles.DefaultStorage(exchange, symbol, interval)
 	if err != nil {
-		errorPrint(errorWrap("get total candles", err))
-		return exitError
+		return nil, err
 	}
-
-	intChan := make(chan os.Signal, 1)
-	signal.Notify(intChan, os.Interrupt, syscall.SIGTERM)
-
-	err = candles.Load(t, stg, intChan, opts.Symbol)
+	n, err := stg.Recover()
 	if err != nil {
-		if errors.Is(err, candles.ErrInterrupted) {
-			fmt.Println("Interrupted!")
-			return exitInterrupt
-		}
-		errorPrint(err)
-		return exitError
+		stg.Close()
+		return nil, err
 	}
-
-	totalCandles2, err := stg.SizeCandles()
-	if err != nil {
-		errorPrint(errorWrap("get total candles", err))
-		return exitError
+	if n > 0 {
+		errorPrint(fmt.Errorf("warning: %s %s: removed %d bytes of a partial candle at the end of the file", symbol, interval, n))
 	}
+	return stg, nil
+}
 
-	fmt.Printf("All done! Loaded %d candles, total candles %d\n", totalCandles2-totalCandles1, totalCandles2)
-	return exitOk
+// Print the first or the last close time of every symbol,
+// the symbol is printed only when there are many of them
+func showTimes(opts *options) int {
+	code := exitOk
+	for _, symbol := range opts.Symbols {
+		stg, err := candles.DefaultStorage(opts.Exchange, symbol, opts.Interval)
+		if err != nil {
+			errorPrint(errorWrap(symbol+": open storage", err))
+			code = exitError
+			continue
+		}
+		var t int64
+		if opts.ShowStart {
+			t, err = stg.FirstCandleCloseTime()
+		} else {
+			t, err = stg.LastCandleCloseTime()
+		}
+		stg.Close()
+		if err != nil {
+			errorPrint(errorWrap(symbol+": read storage close time", err))
+			code = exitError
+			continue
+		}
+		if len(opts.Symbols) > 1 {
+			os.Stdout.WriteString(symbol + " ")
+		}
+		datePrint(t)
+	}
+	return code
 }
 
 func main() {
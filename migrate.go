@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/k0l1br1/loader/candles"
+)
+
+const migrateUsage = `usage: loader migrate -f <file> [options]
+    -f, --file          The headerless data file to convert
+    -s, --symbol        The pair of the data (default is the file name)
+    -i, --interval      The kline interval (default is inferred from the data)
+    -o, --output        The path of the new file (default is the default name
+                        of the symbol and the interval next to the source)
+    --encoding          float32 or float64 (default float32)
+`
+
+var (
+	errReqFile      = errors.New("file is required")
+	errOutputExists = errors.New("output file already exists")
+)
+
+type migrateOptions struct {
+	File     string
+	Symbol   string
+	Interval candles.Interval
+	Output   string
+	Encoding candles.Encoding
+}
+
+func parseMigrateOptions(args []string) (*migrateOptions, error) {
+	if len(args) < 2 {
+		printUsage(migrateUsage)
+	}
+	opts := &migrateOptions{}
+
+	// args[0] is the command name
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		j := i + 1
+		hasValue := len(args) > j && !strings.HasPrefix(args[j], "-")
+		switch arg {
+		case "-h", "--help":
+			printUsage(migrateUsage)
+		case "-f", "--file":
+			if hasValue {
+				opts.File = args[j]
+				i++
+			}
+		case "-s", "--symbol":
+			if hasValue {
+				opts.Symbol = strings.ToUpper(args[j])
+				i++
+			}
+		case "-i", "--interval":
+			if hasValue {
+				interval, err := candles.ParseInterval(args[j])
+				if err != nil {
+					return nil, errorWrap("parse options interval", err)
+				}
+				opts.Interval = interval
+				i++
+			}
+		case "-o", "--output":
+			if hasValue {
+				opts.Output = args[j]
+				i++
+			}
+		case "--encoding":
+			if hasValue {
+				e, err := candles.ParseEncoding(args[j])
+				if err != nil {
+					return nil, errorWrap("parse options encoding", err)
+				}
+				opts.Encoding = e
+				i++
+			}
+		}
+	}
+
+	if opts.File == "" {
+		return nil, errReqFile
+	}
+	if opts.Symbol == "" {
+		// the first loader stored a symbol as candles/BTCUSDT.bin
+		name := filepath.Base(opts.File)
+		opts.Symbol = strings.ToUpper(strings.TrimSuffix(name, filepath.Ext(name)))
+	}
+	return opts, nil
+}
+
+func runMigrate(args []string) int {
+	opts, err := parseMigrateOptions(args)
+	if err != nil {
+		errorPrint(err)
+		if err == errReqFile {
+			return exitOk
+		}
+		return exitError
+	}
+
+	if opts.Interval == "" {
+		opts.Interval, err = candles.InferLegacyInterval(opts.File)
+		if err != nil {
+			errorPrint(errorWrap("infer interval, set it with -i", err))
+			return exitError
+		}
+	}
+	if opts.Output == "" {
+		dir := filepath.Dir(opts.File)
+		opts.Output = filepath.Join(dir, candles.DefaultFileName(opts.Symbol, opts.Interval))
+	}
+	// never replace a dataset, the rename would do it silently
+	if _, err = os.Stat(opts.Output); err == nil {
+		errorPrint(errorWrap(opts.Output, errOutputExists))
+		return exitError
+	}
+
+	h := candles.Header{
+		Format:   candles.Format{Layout: candles.LayoutCompact, Encoding: opts.Encoding},
+		Symbol:   opts.Symbol,
+		Interval: opts.Interval,
+		Exchange: candles.DefaultExchange,
+	}
+	n, err := candles.Migrate(opts.File, opts.Output, h)
+	if err != nil {
+		errorPrint(errorWrap("migrate", err))
+		return exitError
+	}
+
+	fmt.Printf("Migrated %d %s %s candles to %s\n", n, opts.Symbol, opts.Interval, opts.Output)
+	return exitOk
+}
@@ -1,17 +1,57 @@
 package main
 
 import (
+	"crypto/tls"
 	"errors"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/k0l1br1/loader/candles"
 )
 
 const usage = `usage: loader -s <symbol> [options]
-    -s, --symbol        The pair for which need to load the prices data
-    -n, --is-new        The flag to init new instance for a symbol    
+       loader migrate -f <file> [options]
+       loader gaps -s <symbol> [options]
+       loader import-archive [options] <file.zip>...
+       loader import-csv -f <file> -s <symbol> [options]
+       loader export -s <symbol> [options]
+       loader resample -s <symbol> -t <intervals> [options]
+    -s, --symbol        The pair for which need to load the prices data,
+                        many pairs are separated by commas (btcusdt,ethusdt)
+    -f, --symbols-file  The file with a pair per line, empty lines and lines
+                        starting with # are skipped
+    -w, --workers       How many pairs to load at the same time (default 4)
+    -n, --is-new        The flag to init new instance for a symbol
     -t, --start-time    Date (UTC) from which to start downloading
                         (format like 2024-02-19 03:37:05)
+    -e, --end-time      Date (UTC) at which to stop downloading, candles
+                        closing later are not loaded (default now)
+    --exchange          The exchange to load from, binance, bybit or binance
+                        futures: binance-usdm, binance-coinm and their
+                        -mark, -index and -premium price klines (default
+                        binance), data of others is in candles/<exchange>
+    -i, --interval      The kline interval, one of 1s 1m 3m 5m 15m 30m 1h 2h
+                        4h 6h 8h 12h 1d 3d 1w 1M (default 1s)
+    -x, --extended      Store quote volume, number of trades and taker buy
+                        volumes along with OHLCV
+    --encoding          How to store prices and volumes of a new instance,
+                        float32 or float64 (default float32, float64 is exact)
+    --retries           How many times to repeat a request failed because of
+                        the network or the server (default 5)
+    --retry-delay       The delay before the first retry, it doubles with
+                        every next one (default 500ms)
+    --weight-limit      The request weight allowed per minute, the loader
+                        waits for the next minute before going over it
+                        (default 6000, 2400 for futures)
+    --api-url           The base URL of the exchange API, e.g. a mirror, the
+                        testnet or a local http mock (default the exchange
+                        one, or the environment variable of the API)
+    --insecure          Do not verify the TLS certificate of the API
+    --sync              When to commit the candles to the disk, batch after
+                        every request or close at the end (default batch)
     --show-start        Show the close date (UTC) of the first candle
     --show-end          Show the close date (UTC) of the last candle
 `
@@ -21,11 +61,21 @@ var (
 	errReqStartTime = errors.New("start-time is required for a new instance")
 	// it is not clear what the user wanted, or start a new download
 	// or continue saved
-	errTimeWithoutNew = errors.New("start-time is required only for a new instance")
+	errTimeWithoutNew     = errors.New("start-time is required only for a new instance")
+	errEndBeforeStart     = errors.New("end-time must be after start-time")
+	errInvalidRetries     = errors.New("retries must be a non negative number")
+	errInvalidWeightLimit = errors.New("weight limit must be a positive number")
+	errInvalidWorkers     = errors.New("workers must be a positive number")
 )
 
+const defaultWorkers = 4
+
 func help() {
-	os.Stdout.WriteString(usage)
+	printUsage(usage)
+}
+
+func printUsage(u string) {
+	os.Stdout.WriteString(u)
 	os.Exit(exitOk)
 }
 
@@ -33,8 +83,19 @@ type options struct {
 	IsNew          bool
 	ShowStart      bool
 	ShowEnd        bool
-	Symbol         string
+	Extended       bool
+	Exchange       string
+	Symbols        []string
+	Workers        int
+	Interval       candles.Interval
+	Encoding       candles.Encoding
 	StartTimestamp int64
+	EndTimestamp   int64
+	Retry          candles.RetryPolicy
+	WeightLimit    int    // 0 is the limit of the exchange
+	BaseURL        string // empty is the environment variable of the API or the exchange one
+	Insecure       bool
+	Durability     candles.Durability
 }
 
 func convertTimeToTimestamp(date string) (int64, error) {
@@ -46,8 +107,29 @@ func convertTimeToTimestamp(date string) (int64, error) {
 	return t.UnixMilli(), nil
 }
 
+// Check the exchange is supported, the names are lower case
+func parseExchange(s string) (string, error) {
+	s = strings.ToLower(s)
+	if !slices.Contains(candles.Exchanges(), s) {
+		return "", errorWrap(s, candles.ErrUnknownExchange)
+	}
+	return s, nil
+}
+
+// Add upper case symbols skipping empty ones, comments and duplicates
+func appendSymbols(dst []string, symbols []string) []string {
+	for _, s := range symbols {
+		s = strings.ToUpper(strings.TrimSpace(s))
+		if s == "" || strings.HasPrefix(s, "#") || slices.Contains(dst, s) {
+			continue
+		}
+		dst = append(dst, s)
+	}
+	return dst
+}
+
 func validateOptions(opts *options) error {
-	if opts.Symbol == "" {
+	if len(opts.Symbols) == 0 {
 		return errReqSymbol
 	}
 	if opts.StartTimestamp == 0 && opts.IsNew {
@@ -56,6 +138,9 @@ func validateOptions(opts *options) error {
 	if opts.StartTimestamp != 0 && !opts.IsNew {
 		return errTimeWithoutNew
 	}
+	if opts.EndTimestamp != 0 && opts.EndTimestamp <= opts.StartTimestamp {
+		return errEndBeforeStart
+	}
 	return nil
 }
 
@@ -63,7 +148,13 @@ func parseOptions(args []string) (*options, error) {
 	if len(args) < 2 {
 		help()
 	}
-	opts := &options{}
+	opts := &options{
+		Exchange:   candles.DefaultExchange,
+		Interval:   candles.DefaultInterval,
+		Retry:      candles.DefaultRetryPolicy(),
+		Workers:    defaultWorkers,
+		Durability: candles.SyncPerBatch,
+	}
 
 	for i := 1; i < len(args); i++ {
 		arg := args[i]
@@ -77,7 +168,27 @@ func parseOptions(args []string) (*options, error) {
 			// during validation there will be a check for an empty string
 			// it is not necessary to check it here
 			if len(args) > j && !strings.HasPrefix(args[j], "-") {
-				opts.Symbol = strings.ToUpper(args[j])
+				opts.Symbols = appendSymbols(opts.Symbols, strings.Split(args[j], ","))
+				i++
+			}
+		case "-f", "--symbols-file":
+			j := i + 1
+			if len(args) > j && !strings.HasPrefix(args[j], "-") {
+				b, err := os.ReadFile(args[j])
+				if err != nil {
+					return nil, errorWrap("parse options symbols file", err)
+				}
+				opts.Symbols = appendSymbols(opts.Symbols, strings.Split(string(b), "\n"))
+				i++
+			}
+		case "-w", "--workers":
+			j := i + 1
+			if len(args) > j && !strings.HasPrefix(args[j], "-") {
+				n, err := strconv.Atoi(args[j])
+				if err != nil || n <= 0 {
+					return nil, errorWrap("parse options workers", errInvalidWorkers)
+				}
+				opts.Workers = n
 				i++
 			}
 		case "-t", "--start-time":
@@ -90,6 +201,100 @@ func parseOptions(args []string) (*options, error) {
 				opts.StartTimestamp = t
 				i++
 			}
+		case "-e", "--end-time":
+			j := i + 1
+			if len(args) > j && !strings.HasPrefix(args[j], "-") {
+				t, err := convertTimeToTimestamp(args[j])
+				if err != nil {
+					return nil, errorWrap("parse options end time", err)
+				}
+				opts.EndTimestamp = t
+				i++
+			}
+		case "--exchange":
+			j := i + 1
+			if len(args) > j && !strings.HasPrefix(args[j], "-") {
+				exchange, err := parseExchange(args[j])
+				if err != nil {
+					return nil, errorWrap("parse options exchange", err)
+				}
+				opts.Exchange = exchange
+				i++
+			}
+		case "-i", "--interval":
+			j := i + 1
+			if len(args) > j && !strings.HasPrefix(args[j], "-") {
+				// 1m and 1M are different intervals, keep the case as is
+				interval, err := candles.ParseInterval(args[j])
+				if err != nil {
+					return nil, errorWrap("parse options interval", err)
+				}
+				opts.Interval = interval
+				i++
+			}
+		case "--encoding":
+			j := i + 1
+			if len(args) > j && !strings.HasPrefix(args[j], "-") {
+				e, err := candles.ParseEncoding(args[j])
+				if err != nil {
+					return nil, errorWrap("parse options encoding", err)
+				}
+				opts.Encoding = e
+				i++
+			}
+		case "--retries":
+			j := i + 1
+			if len(args) > j && !strings.HasPrefix(args[j], "-") {
+				n, err := strconv.Atoi(args[j])
+				if err != nil || n < 0 {
+					return nil, errorWrap("parse options retries", errInvalidRetries)
+				}
+				opts.Retry.MaxRetries = n
+				i++
+			}
+		case "--retry-delay":
+			j := i + 1
+			if len(args) > j && !strings.HasPrefix(args[j], "-") {
+				d, err := time.ParseDuration(args[j])
+				if err != nil {
+					return nil, errorWrap("parse options retry delay", err)
+				}
+				opts.Retry.MinDelay = d
+				i++
+			}
+		case "--weight-limit":
+			j := i + 1
+			if len(args) > j && !strings.HasPrefix(args[j], "-") {
+				n, err := strconv.Atoi(args[j])
+				if err != nil || n <= 0 {
+					return nil, errorWrap("parse options weight limit", errInvalidWeightLimit)
+				}
+				opts.WeightLimit = n
+				i++
+			}
+		case "--api-url":
+			j := i + 1
+			if len(args) > j && !strings.HasPrefix(args[j], "-") {
+				if err := candles.CheckBaseURL(args[j]); err != nil {
+					return nil, errorWrap("parse options api url", err)
+				}
+				opts.BaseURL = args[j]
+				i++
+			}
+		case "--insecure":
+			opts.Insecure = true
+		case "--sync":
+			j := i + 1
+			if len(args) > j && !strings.HasPrefix(args[j], "-") {
+				d, err := candles.ParseDurability(args[j])
+				if err != nil {
+					return nil, errorWrap("parse options sync", err)
+				}
+				opts.Durability = d
+				i++
+			}
+		case "-x", "--extended":
+			opts.Extended = true
 		case "--show-start":
 			opts.ShowStart = true
 		case "--show-end":
@@ -102,3 +307,11 @@ func parseOptions(args []string) (*options, error) {
 	}
 	return opts, nil
 }
+
+// TLS settings of the API client, nil is the default
+func (o *options) tlsConfig() *tls.Config {
+	if !o.Insecure {
+		return nil
+	}
+	return &tls.Config{InsecureSkipVerify: true}
+}
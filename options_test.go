@@ -2,7 +2,13 @@ package main
 
 import (
 	"errors"
+	"os"
+	"slices"
 	"testing"
+	"time"
+
+	"github.com/k0l1br1/loader/candles"
+	"github.com/k0l1br1/loader/resample"
 )
 
 func TestOptionsParser(t *testing.T) {
@@ -14,8 +20,8 @@ func TestOptionsParser(t *testing.T) {
 
 	opts, _ := parseOptions(args)
 	wantSymbol := "ETHUSDT"
-	if opts.Symbol != wantSymbol {
-		t.Errorf("parse symbol want %s, got %s", wantSymbol, opts.Symbol)
+	if len(opts.Symbols) != 1 || opts.Symbols[0] != wantSymbol {
+		t.Errorf("parse symbol want %s, got %v", wantSymbol, opts.Symbols)
 	}
 
 	args = append(args, "--is-new")
@@ -41,3 +47,340 @@ func TestOptionsParser(t *testing.T) {
 		t.Errorf("parse symbol want %d, got %d", wantTimestamp, opts.StartTimestamp)
 	}
 }
+
+func TestOptionsInterval(t *testing.T) {
+	opts, err := parseOptions([]string{"loader", "-s", "btcusdt"})
+	if err != nil {
+		t.Fatalf("parse options error %s", err.Error())
+	}
+	if opts.Interval != candles.Interval1s {
+		t.Errorf("default interval want %s, got %s", candles.Interval1s, opts.Interval)
+	}
+
+	opts, err = parseOptions([]string{"loader", "-s", "btcusdt", "-i", "1M"})
+	if err != nil {
+		t.Fatalf("parse options error %s", err.Error())
+	}
+	if opts.Interval != candles.Interval1M {
+		t.Errorf("parse interval want %s, got %s", candles.Interval1M, opts.Interval)
+	}
+
+	_, err = parseOptions([]string{"loader", "-s", "btcusdt", "--interval", "2m"})
+	if !errors.Is(err, candles.ErrInvalidInterval) {
+		t.Errorf("want error '%s', got '%v'", candles.ErrInvalidInterval, err)
+	}
+}
+
+func TestOptionsEncoding(t *testing.T) {
+	opts, err := parseOptions([]string{"loader", "-s", "btcusdt", "--encoding", "float64"})
+	if err != nil {
+		t.Fatalf("parse options error %s", err.Error())
+	}
+	if opts.Encoding != candles.EncodingFloat64 {
+		t.Errorf("parse encoding want %s, got %s", candles.EncodingFloat64, opts.Encoding)
+	}
+
+	_, err = parseOptions([]string{"loader", "-s", "btcusdt", "--encoding", "decimal"})
+	if !errors.Is(err, candles.ErrInvalidEncoding) {
+		t.Errorf("want error '%s', got '%v'", candles.ErrInvalidEncoding, err)
+	}
+}
+
+func TestMigrateOptions(t *testing.T) {
+	_, err := parseMigrateOptions([]string{"migrate", "-i", "1s"})
+	if !errors.Is(err, errReqFile) {
+		t.Errorf("want error '%s', got '%v'", errReqFile, err)
+	}
+
+	opts, err := parseMigrateOptions([]string{"migrate", "-f", "candles/btcusdt.bin"})
+	if err != nil {
+		t.Fatalf("parse options error %s", err.Error())
+	}
+	if opts.Symbol != "BTCUSDT" {
+		t.Errorf("symbol from the file name want BTCUSDT, got %s", opts.Symbol)
+	}
+	if opts.Interval != "" {
+		t.Errorf("interval must be inferred, got %s", opts.Interval)
+	}
+}
+
+func TestGapsOptions(t *testing.T) {
+	_, err := parseGapsOptions([]string{"gaps", "--repair"})
+	if !errors.Is(err, errReqSymbol) {
+		t.Errorf("want error '%s', got '%v'", errReqSymbol, err)
+	}
+
+	opts, err := parseGapsOptions([]string{"gaps", "-s", "btcusdt", "-i", "1m", "-r"})
+	if err != nil {
+		t.Fatalf("parse options error %s", err.Error())
+	}
+	if opts.Symbol != "BTCUSDT" || opts.Interval != candles.Interval1m || !opts.Repair {
+		t.Errorf("parse gaps options: got %#v", opts)
+	}
+}
+
+func TestOptionsRetry(t *testing.T) {
+	opts, err := parseOptions([]string{"loader", "-s", "btcusdt", "--retries", "0", "--retry-delay", "2s"})
+	if err != nil {
+		t.Fatalf("parse options error %s", err.Error())
+	}
+	if opts.Retry.MaxRetries != 0 || opts.Retry.MinDelay != 2*time.Second {
+		t.Errorf("parse retry options: got %#v", opts.Retry)
+	}
+
+	_, err = parseOptions([]string{"loader", "-s", "btcusdt", "--retries", "many"})
+	if !errors.Is(err, errInvalidRetries) {
+		t.Errorf("want error '%s', got '%v'", errInvalidRetries, err)
+	}
+
+	_, err = parseOptions([]string{"loader", "-s", "btcusdt", "--weight-limit", "0"})
+	if !errors.Is(err, errInvalidWeightLimit) {
+		t.Errorf("want error '%s', got '%v'", errInvalidWeightLimit, err)
+	}
+}
+
+func TestOptionsSymbols(t *testing.T) {
+	file := "/tmp/test-loader-symbols.txt"
+	if err := os.WriteFile(file, []byte("# majors\nbtcusdt\n\n ethusdt \nsolusdt\n"), 0644); err != nil {
+		t.Fatalf("write symbols file: %s", err.Error())
+	}
+	opts, err := parseOptions([]string{"loader", "-s", "btcusdt,bnbusdt", "-f", file, "-w", "2"})
+	if err != nil {
+		t.Fatalf("parse options error %s", err.Error())
+	}
+	want := []string{"BTCUSDT", "BNBUSDT", "ETHUSDT", "SOLUSDT"}
+	if !slices.Equal(opts.Symbols, want) {
+		t.Errorf("parse symbols want %v, got %v", want, opts.Symbols)
+	}
+	if opts.Workers != 2 {
+		t.Errorf("parse workers want 2, got %d", opts.Workers)
+	}
+
+	_, err = parseOptions([]string{"loader", "-s", "btcusdt", "-w", "0"})
+	if !errors.Is(err, errInvalidWorkers) {
+		t.Errorf("want error '%s', got '%v'", errInvalidWorkers, err)
+	}
+}
+
+func TestOptionsEndTime(t *testing.T) {
+	args := []string{"loader", "-s", "btcusdt", "-n", "-t", "2023-03-01 00:00:00", "-e", "2023-04-01 00:00:00"}
+	opts, err := parseOptions(args)
+	if err != nil {
+		t.Fatalf("parse options error %s", err.Error())
+	}
+	var wantTimestamp int64 = 1680307200000
+	if opts.EndTimestamp != wantTimestamp {
+		t.Errorf("parse end time want %d, got %d", wantTimestamp, opts.EndTimestamp)
+	}
+
+	args = []string{"loader", "-s", "btcusdt", "-n", "-t", "2023-03-01 00:00:00", "-e", "2023-02-01 00:00:00"}
+	_, err = parseOptions(args)
+	if !errors.Is(err, errEndBeforeStart) {
+		t.Errorf("want error '%s', got '%v'", errEndBeforeStart, err)
+	}
+}
+
+func TestOptionsExchange(t *testing.T) {
+	opts, err := parseOptions([]string{"loader", "-s", "btcusdt", "--exchange", "Bybit"})
+	if err != nil {
+		t.Fatalf("parse options error %s", err.Error())
+	}
+	if opts.Exchange != candles.ExchangeBybit {
+		t.Errorf("parse exchange want %s, got %s", candles.ExchangeBybit, opts.Exchange)
+	}
+
+	opts, err = parseOptions([]string{"loader", "-s", "btcusdt", "--exchange", "binance-usdm-mark"})
+	if err != nil {
+		t.Fatalf("parse options error %s", err.Error())
+	}
+	if opts.Exchange != candles.ExchangeBinanceUSDMMark {
+		t.Errorf("parse exchange want %s, got %s", candles.ExchangeBinanceUSDMMark, opts.Exchange)
+	}
+
+	_, err = parseOptions([]string{"loader", "-s", "btcusdt", "--exchange", "mtgox"})
+	if !errors.Is(err, candles.ErrUnknownExchange) {
+		t.Errorf("want error '%s', got '%v'", candles.ErrUnknownExchange, err)
+	}
+}
+
+func TestOptionsBaseURL(t *testing.T) {
+	// the variable is read by the source of its API
+	t.Setenv(candles.EnvBaseURL, "https://testnet.binance.vision")
+	opts, err := parseOptions([]string{"loader", "-s", "btcusdt"})
+	if err != nil {
+		t.Fatalf("parse options error %s", err.Error())
+	}
+	if opts.BaseURL != "" || opts.tlsConfig() != nil {
+		t.Errorf("parse base url want empty, got %s", opts.BaseURL)
+	}
+
+	opts, err = parseOptions([]string{"loader", "-s", "btcusdt", "--api-url", "http://127.0.0.1:8080", "--insecure"})
+	if err != nil {
+		t.Fatalf("parse options error %s", err.Error())
+	}
+	if opts.BaseURL != "http://127.0.0.1:8080" {
+		t.Errorf("parse base url want http://127.0.0.1:8080, got %s", opts.BaseURL)
+	}
+	if c := opts.tlsConfig(); c == nil || !c.InsecureSkipVerify {
+		t.Errorf("parse insecure want InsecureSkipVerify, got %v", c)
+	}
+
+	_, err = parseOptions([]string{"loader", "-s", "btcusdt", "--api-url", "api.binance.us"})
+	if !errors.Is(err, candles.ErrInvalidBaseURL) {
+		t.Errorf("want error '%s', got '%v'", candles.ErrInvalidBaseURL, err)
+	}
+}
+
+func TestOptionsDurability(t *testing.T) {
+	opts, err := parseOptions([]string{"loader", "-s", "btcusdt"})
+	if err != nil {
+		t.Fatalf("parse options error %s", err.Error())
+	}
+	if opts.Durability != candles.SyncPerBatch {
+		t.Errorf("parse sync want batch, got %d", opts.Durability)
+	}
+	opts, err = parseOptions([]string{"loader", "-s", "btcusdt", "--sync", "close"})
+	if err != nil {
+		t.Fatalf("parse options error %s", err.Error())
+	}
+	if opts.Durability != candles.SyncOnClose {
+		t.Errorf("parse sync want close, got %d", opts.Durability)
+	}
+	_, err = parseOptions([]string{"loader", "-s", "btcusdt", "--sync", "never"})
+	if !errors.Is(err, candles.ErrInvalidDurability) {
+		t.Errorf("want error '%s', got '%v'", candles.ErrInvalidDurability, err)
+	}
+}
+
+func TestImportArchiveOptions(t *testing.T) {
+	opts, err := parseImportArchiveOptions([]string{"import-archive", "-n",
+		"BTCUSDT-1m-2024-02-19.zip", "BTCUSDT-1m-2024-02.zip", "BTCUSDT-1m-2024-01.zip"})
+	if err != nil {
+		t.Fatalf("parse options error %s", err.Error())
+	}
+	if err = sortArchives(opts); err != nil {
+		t.Fatalf("sort archives error %s", err.Error())
+	}
+	if opts.Symbol != "BTCUSDT" || opts.Interval != candles.Interval1m {
+		t.Errorf("archive dataset want BTCUSDT 1m, got %s %s", opts.Symbol, opts.Interval)
+	}
+	want := []string{"BTCUSDT-1m-2024-01.zip", "BTCUSDT-1m-2024-02.zip", "BTCUSDT-1m-2024-02-19.zip"}
+	if !slices.Equal(opts.Files, want) {
+		t.Errorf("archive order want %v, got %v", want, opts.Files)
+	}
+
+	opts, err = parseImportArchiveOptions([]string{"import-archive", "BTCUSDT-1mo-2024-02.zip", "BTCUSDT-1mo-2024-01.zip"})
+	if err != nil {
+		t.Fatalf("parse options error %s", err.Error())
+	}
+	if err = sortArchives(opts); err != nil {
+		t.Fatalf("sort monthly interval archives error %s", err.Error())
+	}
+	if opts.Interval != candles.Interval1M || opts.Files[0] != "BTCUSDT-1mo-2024-01.zip" {
+		t.Errorf("monthly interval archives want 1M from 2024-01, got %s %v", opts.Interval, opts.Files)
+	}
+
+	opts, err = parseImportArchiveOptions([]string{"import-archive", "BTCUSDT-1m-2024-01.zip", "ETHUSDT-1m-2024-01.zip"})
+	if err != nil {
+		t.Fatalf("parse options error %s", err.Error())
+	}
+	if err = sortArchives(opts); !errors.Is(err, errArchiveMismatch) {
+		t.Errorf("want error '%s', got '%v'", errArchiveMismatch, err)
+	}
+
+	_, err = parseImportArchiveOptions([]string{"import-archive", "-n"})
+	if err != errReqArchive {
+		t.Errorf("want error '%s', got '%v'", errReqArchive, err)
+	}
+}
+
+func TestImportCSVOptions(t *testing.T) {
+	args := []string{"import-csv", "-f", "ethusd.csv", "-s", "ethusd", "-d", "tab", "--header",
+		"--columns", "open_time=time,open=o,high=h,low=l,close=c", "--time-unit", "s"}
+	opts, err := parseImportCSVOptions(args)
+	if err != nil {
+		t.Fatalf("parse options error %s", err.Error())
+	}
+	if opts.Symbol != "ETHUSD" || opts.CSV.Comma != '\t' || !opts.CSV.Header || opts.CSV.TimeUnit != candles.TimeUnitSeconds {
+		t.Errorf("parse csv options got %#v", opts)
+	}
+	if opts.CSV.Columns["open_time"] != "time" {
+		t.Errorf("parse columns want open_time=time, got %v", opts.CSV.Columns)
+	}
+
+	opts, err = parseImportCSVOptions([]string{"import-csv", "-f", "a.csv", "-s", "ethusd", "-d", "-"})
+	if err != nil {
+		t.Fatalf("parse options error %s", err.Error())
+	}
+	if opts.CSV.Comma != '-' {
+		t.Errorf("parse delimiter want '-', got %q", opts.CSV.Comma)
+	}
+
+	_, err = parseImportCSVOptions([]string{"import-csv", "-f", "a.csv", "-s", "ethusd", "-d", ";;"})
+	if !errors.Is(err, errInvalidDelimiter) {
+		t.Errorf("want error '%s', got '%v'", errInvalidDelimiter, err)
+	}
+	_, err = parseImportCSVOptions([]string{"import-csv", "-f", "a.csv", "-s", "ethusd", "--time-unit", "h"})
+	if !errors.Is(err, candles.ErrInvalidTimeUnit) {
+		t.Errorf("want error '%s', got '%v'", candles.ErrInvalidTimeUnit, err)
+	}
+	_, err = parseImportCSVOptions([]string{"import-csv", "-f", "a.csv"})
+	if err != errReqSymbol {
+		t.Errorf("want error '%s', got '%v'", errReqSymbol, err)
+	}
+}
+
+func TestExportOptions(t *testing.T) {
+	args := []string{"export", "-s", "btcusdt", "-o", "feb.jsonl", "--columns", "open_time,close",
+		"--time-format", "RFC3339", "--from", "2024-02-01 00:00:00", "--to", "2024-03-01 00:00:00"}
+	opts, err := parseExportOptions(args)
+	if err != nil {
+		t.Fatalf("parse options error %s", err.Error())
+	}
+	if opts.Format != exportJSONL || opts.Export.TimeFormat != candles.TimeFormatRFC3339 {
+		t.Errorf("parse export format want jsonl rfc3339, got %s %s", opts.Format, opts.Export.TimeFormat)
+	}
+	if len(opts.Export.Columns) != 2 || opts.Export.From != 1706745600000 || opts.Export.To != 1709251200000 {
+		t.Errorf("parse export options got %#v", opts.Export)
+	}
+
+	opts, err = parseExportOptions([]string{"export", "-s", "btcusdt"})
+	if err != nil {
+		t.Fatalf("parse options error %s", err.Error())
+	}
+	if opts.Format != exportCSV {
+		t.Errorf("parse export format want csv, got %s", opts.Format)
+	}
+
+	opts, err = parseExportOptions([]string{"export", "-s", "btcusdt", "-o", "btcusdt.parquet", "--row-group", "1000"})
+	if err != nil {
+		t.Fatalf("parse options error %s", err.Error())
+	}
+	if opts.Format != exportParquet || opts.Export.RowGroupRows != 1000 {
+		t.Errorf("parse export format want parquet of 1000 rows, got %s of %d", opts.Format, opts.Export.RowGroupRows)
+	}
+
+	_, err = parseExportOptions([]string{"export", "-s", "btcusdt", "--format", "xml"})
+	if !errors.Is(err, errInvalidExportFormat) {
+		t.Errorf("want error '%s', got '%v'", errInvalidExportFormat, err)
+	}
+}
+
+func TestResampleOptions(t *testing.T) {
+	opts, err := parseResampleOptions([]string{"resample", "-s", "btcusdt", "-t", "1m,7s,90m"})
+	if err != nil {
+		t.Fatalf("parse options error %s", err.Error())
+	}
+	if opts.Interval != candles.Interval1s || len(opts.Targets) != 3 || opts.Targets[2] != "90m" {
+		t.Errorf("parse resample options got %s %v", opts.Interval, opts.Targets)
+	}
+
+	_, err = parseResampleOptions([]string{"resample", "-s", "btcusdt", "-i", "1m", "-t", "90s"})
+	if !errors.Is(err, resample.ErrIntervalMismatch) {
+		t.Errorf("want error '%s', got '%v'", resample.ErrIntervalMismatch, err)
+	}
+	_, err = parseResampleOptions([]string{"resample", "-s", "btcusdt"})
+	if err != errReqTarget {
+		t.Errorf("want error '%s', got '%v'", errReqTarget, err)
+	}
+}
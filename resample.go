@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/k0l1br1/loader/candles"
+	"github.com/k0l1br1/loader/resample"
+)
+
+const resampleUsage = `usage: loader resample -s <symbol> -t <intervals> [options]
+    -s, --symbol        The pair of the data
+    -i, --interval      The interval of the source data (default 1s)
+    -t, --target        The intervals to derive separated by commas, exchange
+                        ones or a number and a unit of s m h d w like 7s or 90m
+    --exchange          The exchange of the data (default binance)
+`
+
+var errReqTarget = errors.New("target interval is required")
+
+type resampleOptions struct {
+	Exchange string
+	Symbol   string
+	Interval candles.Interval
+	Targets  []candles.Interval
+}
+
+func parseResampleOptions(args []string) (*resampleOptions, error) {
+	if len(args) < 2 {
+		printUsage(resampleUsage)
+	}
+	opts := &resampleOptions{Exchange: candles.DefaultExchange, Interval: candles.DefaultInterval}
+
+	// args[0] is the command name
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		j := i + 1
+		hasValue := len(args) > j && !strings.HasPrefix(args[j], "-")
+		switch arg {
+		case "-h", "--help":
+			printUsage(resampleUsage)
+		case "-s", "--symbol":
+			if hasValue {
+				opts.Symbol = strings.ToUpper(args[j])
+				i++
+			}
+		case "-i", "--interval":
+			if hasValue {
+				interval, err := candles.ParseDerivedInterval(args[j])
+				if err != nil {
+					return nil, errorWrap("parse options interval", err)
+				}
+				opts.Interval = interval
+				i++
+			}
+		case "-t", "--target":
+			if hasValue {
+				for _, s := range strings.Split(args[j], ",") {
+					interval, err := candles.ParseDerivedInterval(s)
+					if err != nil {
+						return nil, errorWrap("parse options target", err)
+					}
+					opts.Targets = append(opts.Targets, interval)
+				}
+				i++
+			}
+		case "--exchange":
+			if hasValue {
+				exchange, err := parseExchange(args[j])
+				if err != nil {
+					return nil, errorWrap("parse options exchange", err)
+				}
+				opts.Exchange = exchange
+				i++
+			}
+		}
+	}
+
+	if opts.Symbol == "" {
+		return nil, errReqSymbol
+	}
+	if len(opts.Targets) == 0 {
+		return nil, errReqTarget
+	}
+	for _, to := range opts.Targets {
+		if _, err := resample.NewAggregator(opts.Interval, to); err != nil {
+			return nil, errorWrap("parse options target", err)
+		}
+	}
+	return opts, nil
+}
+
+// Open the dataset of the target interval, it is created with the format
+// of the source when it does not exist
+func targetStorage(h candles.Header, to candles.Interval) (*candles.Storage, error) {
+	path, err := candles.DefaultPath(h.Exchange, h.Symbol, to)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		h.Interval = to
+		return candles.NewDefaultStorage(h)
+	}
+	return openAppendStorage(h.Exchange, h.Symbol, to)
+}
+
+func runResample(args []string) int {
+	opts, err := parseResampleOptions(args)
+	if err != nil {
+		errorPrint(err)
+		if err == errReqSymbol || err == errReqTarget {
+			return exitOk
+		}
+		return exitError
+	}
+
+	src, err := candles.DefaultStorage(opts.Exchange, opts.Symbol, opts.Interval)
+	if err != nil {
+		errorPrint(errorWrap("open storage", err))
+		return exitError
+	}
+	defer src.Close()
+	h := src.Header()
+
+	for _, to := range opts.Targets {
+		dst, err := targetStorage(h, to)
+		if err != nil {
+			errorPrint(errorWrap(string(to)+": open storage", err))
+			return exitError
+		}
+		n, err := resample.Update(src, dst, opts.Interval, to)
+		if err == nil {
+			err = dst.Sync()
+		}
+		var total int64
+		if err == nil {
+			total, err = dst.SizeCandles()
+		}
+		dst.Close()
+		if err != nil {
+			errorPrint(errorWrap(string(to)+": resample", err))
+			return exitError
+		}
+		fmt.Printf("%s: resampled %d %s candles, total candles %d\n", to, n, opts.Symbol, total)
+	}
+	return exitOk
+}
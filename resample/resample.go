@@ -0,0 +1,133 @@
+// Package resample derives the candles of longer intervals from the stored
+// candles of a shorter one, like 1m or 90m candles from 1s ones
+package resample
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/k0l1br1/loader/candles"
+)
+
+// candles read from the source at once
+const batch = 4096
+
+var ErrIntervalMismatch = errors.New("target interval must be made of whole source candles")
+
+func errorWrap(msg string, err error) error {
+	return fmt.Errorf("%s: %w", msg, err)
+}
+
+// Folds the candles of an interval into the candles of a longer one:
+// the first open, the max high, the min low, the last close and the sums of
+// the volumes and the trades. The source candles are expected to be sorted
+type Aggregator struct {
+	to    candles.Interval
+	cur   candles.Candle
+	open  bool   // cur has some candles
+	whole bool   // the candles of cur follow each other from its open time
+	next  uint32 // open time of the next source candle of cur
+}
+
+// The source interval must divide the target one and the target candles must
+// open with a source one, months are made of candles not longer than a day
+func NewAggregator(from, to candles.Interval) (*Aggregator, error) {
+	fd, td := from.Duration(), to.Duration()
+	if to == candles.Interval1M {
+		td = 24 * time.Hour
+	}
+	if fd <= 0 || td <= 0 {
+		return nil, errorWrap(string(from)+" to "+string(to), candles.ErrInvalidInterval)
+	}
+	// a week opens on a monday, 3d candles do not
+	t := to.OpenTime(0)
+	if (td <= fd && to != candles.Interval1M) || td%fd != 0 || from.OpenTime(t) != t {
+		return nil, errorWrap(string(from)+" to "+string(to), ErrIntervalMismatch)
+	}
+	return &Aggregator{to: to}, nil
+}
+
+// Fold the candles and append the complete candles of the target interval
+// to dst. A candle is complete when a source candle closes at its close time,
+// the candle in progress is kept for the next call. A candle with missing
+// source candles, like the first one of a source which starts in the middle
+// of it, is dropped. Source candles opened before the candle in progress are skipped
+func (a *Aggregator) Add(dst, cs []candles.Candle) []candles.Candle {
+	for i := range cs {
+		c := &cs[i]
+		ot := a.to.OpenTime(candles.SecToMilli(c.OTime))
+		if a.open && ot != candles.SecToMilli(a.cur.OTime) {
+			if ot < candles.SecToMilli(a.cur.OTime) {
+				continue
+			}
+			// some source candles are missing at the end of the current one
+			a.open = false
+		}
+		if !a.open {
+			a.cur = *c
+			a.cur.OTime = uint32(ot / 1000)
+			a.cur.CTime = uint32(a.to.Next(ot) / 1000)
+			a.open = true
+			a.whole = c.OTime == a.cur.OTime
+		} else {
+			if c.OTime != a.next {
+				a.whole = false
+			}
+			a.cur.HPrice = max(a.cur.HPrice, c.HPrice)
+			a.cur.LPrice = min(a.cur.LPrice, c.LPrice)
+			a.cur.CPrice = c.CPrice
+			a.cur.Volume += c.Volume
+			a.cur.QVolume += c.QVolume
+			a.cur.Trades += c.Trades
+			a.cur.TBVolume += c.TBVolume
+			a.cur.TQVolume += c.TQVolume
+		}
+		a.next = c.CTime
+		if c.CTime >= a.cur.CTime {
+			if a.whole {
+				dst = append(dst, a.cur)
+			}
+			a.open = false
+		}
+	}
+	return dst
+}
+
+// Append to dst the candles of the interval to made of the candles of src
+// closed after the last candle of dst. Only complete candles are written, so
+// the next update continues with the candle in progress when src grows.
+// Returns the number of candles written
+func Update(src, dst *candles.Storage, from, to candles.Interval) (int64, error) {
+	a, err := NewAggregator(from, to)
+	if err != nil {
+		return 0, err
+	}
+	last, err := dst.LastCandleCloseTime()
+	if err != nil {
+		return 0, errorWrap("read last close time", err)
+	}
+	cur := src.NewCursor()
+	if _, err = cur.SeekTime(last); err != nil {
+		return 0, errorWrap("seek source", err)
+	}
+
+	var total int64
+	cs := make([]candles.Candle, batch)
+	var out []candles.Candle
+	for {
+		n, err := cur.Next(cs)
+		if err != nil && err != io.EOF {
+			return total, errorWrap("read source", err)
+		}
+		out = a.Add(out[:0], cs[:n])
+		if serr := dst.Save(out); serr != nil {
+			return total, errorWrap("save candles", serr)
+		}
+		total += int64(len(out))
+		if err == io.EOF {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package resample
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/k0l1br1/loader/candles"
+)
+
+func TestNewAggregator(t *testing.T) {
+	for _, c := range [][2]candles.Interval{{"1s", "7s"}, {"1s", "90m"}, {"1m", "1w"}, {"1d", "1M"}, {"1w", "2w"}} {
+		if _, err := NewAggregator(c[0], c[1]); err != nil {
+			t.Errorf("aggregator %s to %s: %s", c[0], c[1], err.Error())
+		}
+	}
+	for _, c := range [][2]candles.Interval{{"1m", "1m"}, {"1m", "90s"}, {"5m", "1m"}, {"3d", "3w"}, {"1w", "1M"}} {
+		if _, err := NewAggregator(c[0], c[1]); !errors.Is(err, ErrIntervalMismatch) {
+			t.Errorf("aggregator %s to %s: want error '%s', got '%v'", c[0], c[1], ErrIntervalMismatch, err)
+		}
+	}
+}
+
+// Returns 1s candles from the open time t, the prices go up by 1 every second
+// from the last digits of t
+func testCandles(t uint32, n int) []candles.Candle {
+	cs := make([]candles.Candle, n)
+	for i := range cs {
+		p := float64(t%1000) + float64(i)
+		ot := t + uint32(i)
+		cs[i] = candles.Candle{
+			OPrice: p, HPrice: p + 0.5, LPrice: p - 0.5, CPrice: p + 0.25, Volume: 1, OTime: ot, CTime: ot + 1,
+			QVolume: 2, Trades: 3, TBVolume: 0.5, TQVolume: 1,
+		}
+	}
+	return cs
+}
+
+func TestUpdate(t *testing.T) {
+	dir := t.TempDir()
+	h := candles.Header{Symbol: "BTCUSDT", Interval: candles.Interval1s, Format: candles.Format{Layout: candles.LayoutExtended}}
+	src, err := candles.NewFileStorage(filepath.Join(dir, "BTCUSDT-1s.bin"), h)
+	if err != nil {
+		t.Fatalf("create source: %s", err.Error())
+	}
+	defer src.Close()
+	h.Interval = "7s"
+	dst, err := candles.NewFileStorage(filepath.Join(dir, "BTCUSDT-7s.bin"), h)
+	if err != nil {
+		t.Fatalf("create target: %s", err.Error())
+	}
+	defer dst.Close()
+
+	// 2024-02-18 23:59:57 is a 7s open time, 17 seconds make 2 candles and 3 seconds more
+	var start uint32 = 1708300797
+	cs := testCandles(start, 17)
+	if err = src.Save(cs); err != nil {
+		t.Fatalf("save source: %s", err.Error())
+	}
+	n, err := Update(src, dst, candles.Interval1s, "7s")
+	if err != nil || n != 2 {
+		t.Fatalf("update: want 2 candles, got %d %v", n, err)
+	}
+
+	// the candle in progress is completed when the source grows
+	if err = src.Save(testCandles(start+17, 4)); err != nil {
+		t.Fatalf("save source: %s", err.Error())
+	}
+	n, err = Update(src, dst, candles.Interval1s, "7s")
+	if err != nil || n != 1 {
+		t.Fatalf("update again: want 1 candle, got %d %v", n, err)
+	}
+
+	got, err := dst.ReadAll()
+	if err != nil {
+		t.Fatalf("read target: %s", err.Error())
+	}
+	if len(got) != 3 {
+		t.Fatalf("target: want 3 candles, got %d", len(got))
+	}
+	for k, c := range got {
+		ot := start + uint32(k*7)
+		p := float64(ot % 1000)
+		want := candles.Candle{
+			OPrice: p, HPrice: p + 6.5, LPrice: p - 0.5, CPrice: p + 6.25, Volume: 7, OTime: ot, CTime: ot + 7,
+			QVolume: 14, Trades: 21, TBVolume: 3.5, TQVolume: 7,
+		}
+		if c != want {
+			t.Errorf("candle %d: want %v, got %v", k, want, c)
+		}
+	}
+}
+
+func TestAggregatorGap(t *testing.T) {
+	a, err := NewAggregator(candles.Interval1s, candles.Interval1m)
+	if err != nil {
+		t.Fatalf("new aggregator: %s", err.Error())
+	}
+	// the first minute misses its last seconds, the second one misses 10 seconds
+	// in the middle, the third one is complete
+	cs := testCandles(1708300800, 30)
+	cs = append(cs, testCandles(1708300860, 20)...)
+	cs = append(cs, testCandles(1708300890, 90)...)
+	out := a.Add(nil, cs)
+	if len(out) != 1 || out[0].OTime != 1708300920 || out[0].CTime != 1708300980 || out[0].Volume != 60 {
+		t.Errorf("gap: want 1 candle of volume 60, got %v", out)
+	}
+}
+
+func TestAggregatorMidInterval(t *testing.T) {
+	a, err := NewAggregator(candles.Interval1s, candles.Interval1m)
+	if err != nil {
+		t.Fatalf("new aggregator: %s", err.Error())
+	}
+	// the source starts 30 seconds after the open of the first minute
+	out := a.Add(nil, testCandles(1708300830, 90))
+	if len(out) != 1 || out[0].OTime != 1708300860 || out[0].Volume != 60 || out[0].OPrice != 860 {
+		t.Errorf("mid interval: want 1 candle of volume 60 from 1708300860, got %v", out)
+	}
+}